@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/asalkeld/scrumpolice/report"
 	"github.com/asalkeld/scrumpolice/scrum"
+	"github.com/nitrictech/go-sdk/api/events"
 	"github.com/slack-go/slack"
 )
 
 var (
-	OutOfOfficeRegex, _ = regexp.Compile("^(.+) is out of office$")
+	OutOfOfficeRegex, _ = regexp.Compile("(?i)^(.+) is out of office$")
 	githubMessages      = []string{
 		"Pull request opened by",
 		"Pull request ready for review by",
@@ -32,16 +36,30 @@ type Bot struct {
 	id   string
 
 	logger *log.Logger
+
+	commands *Commands
+
+	// signingSecret verifies the requests coming from Slack
+	signingSecret string
+	// responseURLs holds the response URL of the slash command an event came from
+	responseURLs sync.Map
+	// work is where the handlers publish what is left to do once they answered Slack
+	work events.Topic
 }
 
-func New(slackApiClient *slack.Client, logger *log.Logger, scrum scrum.Service) *Bot {
+func New(slackApiClient *slack.Client, logger *log.Logger, scrum scrum.Service, signingSecret string, work events.Topic) *Bot {
 	b := &Bot{
-		slackBotAPI: slackApiClient,
-		logger:      logger,
-		scrum:       scrum,
-		name:        "scrumpolice",
-		id:          "",
-	}
+		slackBotAPI:   slackApiClient,
+		logger:        logger,
+		scrum:         scrum,
+		signingSecret: signingSecret,
+		work:          work,
+		name:          "scrumpolice",
+		id:            "",
+		commands:      newCommands(),
+	}
+	scrum.OnDraftTimeout(b.draftTimedOut)
+	scrum.OnNotify(b.publishWebhook)
 
 	return b
 }
@@ -76,76 +94,99 @@ func (b *Bot) handleMessage(event *slack.MessageEvent, isIM bool) {
 
 	adressedToMe := b.adressedToMe(eventText)
 
-	// FROM HERE All Commands need to be adressed to me or handled in private conversations
-	if !isIM && adressedToMe {
-		eventText = b.trimBotNameInMessage(eventText)
-	}
-
 	// From here on i only care of messages that were clearly adressed to me so i'll just get out
 	if !adressedToMe && !isIM {
 		return
 	}
 
-	if !b.HandleScrumMessage(event) {
-		return
+	text := strings.TrimSpace(event.Text)
+	if !isIM {
+		text = b.trimBotNameInMessage(text)
 	}
-
-	// Handle commands adressed to me (can be public or private)
-	if eventText == "source code" {
-		b.sourceCode(event)
-		return
+	if m := OutOfOfficeRegex.FindStringSubmatch(text); m != nil {
+		text = "out of office " + m[1]
 	}
 
-	if strings.HasPrefix(eventText, "report-dm") {
-		teamName := strings.TrimSpace(strings.TrimPrefix(event.Text, "report-dm"))
-		b.sendReportDm(event, teamName, "@"+event.User)
-		return
+	cmd, words, found := b.commands.Route(text)
+	args, err := []string(nil), error(nil)
+	if found {
+		args, err = cmd.ParseArgs(words)
 	}
 
-	if strings.HasPrefix(eventText, "report") {
-		teamName := strings.TrimSpace(strings.TrimPrefix(event.Text, "report"))
-		b.sendReport(event, teamName)
+	// while answering their scrum anything but starting over is an answer, and while
+	// editing an answer so is starting over if it's a valid answer
+	if !(found && err == nil && cmd.DuringScrum && !b.answersEdit(event)) && !b.HandleScrumMessage(event) {
 		return
 	}
 
-	if strings.HasPrefix(eventText, "github-user") {
-		githubUser := strings.TrimSpace(strings.TrimPrefix(event.Text, "github-user"))
-		b.githubUser(event, githubUser)
+	if !found {
+		// Unrecognized message so let's help the user
+		b.unrecognizedMessage(event)
 		return
 	}
+	b.runCommand(event, isIM, cmd, args, err)
+}
 
-	if eventText == "teamlist" {
-		b.teamlist(event)
+// runCommand runs the command if it can be used here by the user with these arguments.
+func (b *Bot) runCommand(event *slack.MessageEvent, isIM bool, cmd *Command, args []string, argsErr error) {
+	params := slack.MsgOptionAsUser(true)
+	if cmd.Scope == DMOnly && !isIM {
+		b.reply(event, slack.MsgOptionText("`"+cmd.Name+"` only works in a direct message to me", true), params)
 		return
 	}
-
-	if eventText == "help" {
-		b.help(event)
+	if argsErr != nil {
+		b.reply(event, slack.MsgOptionText(fmt.Sprintf("Sorry, %s. Try `%s`", argsErr, cmd.Usage()), true), params)
 		return
 	}
-
-	if eventText == "tutorial" {
-		b.tutorial(event)
+	if reason := b.forbidden(event, cmd, args); reason != "" {
+		b.reply(event, slack.MsgOptionText(reason, true), params)
 		return
 	}
 
-	if eventText == "out of office" {
-		b.outOfOffice(event, event.User)
-		return
+	b.logSlackEvent(event, "command "+cmd.Name)
+	cmd.Run(b, event, args)
+}
+
+// forbidden returns why the user can't run the command, empty if they can.
+func (b *Bot) forbidden(event *slack.MessageEvent, cmd *Command, args []string) string {
+	if cmd.Permission == Everyone {
+		return ""
 	}
 
-	if OutOfOfficeRegex.MatchString(eventText) {
-		b.outOfOffice(event, strings.Split(strings.Trim(eventText, " "), " ")[0])
-		return
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return "Hmmmm, I couldn't find you. Try again!"
 	}
+	username := user.Profile.DisplayName
 
-	if eventText == "i'm back" || eventText == "i am back" {
-		b.backInOffice(event)
-		return
+	switch cmd.Permission {
+	case TeamMember:
+		if b.scrum.GetTeamForUser(username) == nil {
+			return "You're not part of a team, you can't use `" + cmd.Name + "`"
+		}
+	case TeamAdmin:
+		tc, err := b.scrum.GetTeamByName(args[0])
+		if err != nil {
+			return "I don't know team " + args[0]
+		}
+		if tc.Admin != "" && tc.Admin != username {
+			return "Only the admin of team " + tc.Name + " can use `" + cmd.Name + "`"
+		}
+		if tc.Admin == "" && !isMember(tc, username) {
+			return "Only the members of team " + tc.Name + " can use `" + cmd.Name + "`"
+		}
 	}
+	return ""
+}
 
-	// Unrecognized message so let's help the user
-	b.unrecognizedMessage(event)
+func isMember(tc *scrum.TeamConfig, username string) bool {
+	for _, member := range tc.Members {
+		if member == username {
+			return true
+		}
+	}
+	return false
 }
 
 func (b *Bot) adressedToMe(msg string) bool {
@@ -154,13 +195,24 @@ func (b *Bot) adressedToMe(msg string) bool {
 }
 
 func (b *Bot) trimBotNameInMessage(msg string) string {
-	msg = strings.Replace(msg, strings.ToLower("<@"+b.id+">"), "", 1)
-	msg = strings.Replace(msg, strings.ToLower(b.name), "", 1)
+	for _, name := range []string{"<@" + b.id + ">", b.name} {
+		if i := strings.Index(strings.ToLower(msg), strings.ToLower(name)); i >= 0 {
+			msg = msg[:i] + msg[i+len(name):]
+		}
+	}
 	msg = strings.Trim(msg, " :\n")
 
 	return msg
 }
 
+// reply answers the event where it came from, ephemerally for a slash command.
+func (b *Bot) reply(event *slack.MessageEvent, options ...slack.MsgOption) (string, string, error) {
+	if url, ok := b.responseURLs.Load(event); ok {
+		options = append(options, slack.MsgOptionResponseURL(url.(string), slack.ResponseTypeEphemeral))
+	}
+	return b.slackBotAPI.PostMessage(event.Channel, options...)
+}
+
 func (b *Bot) reactToEvent(event *slack.MessageEvent, reaction string) {
 	item := slack.ItemRef{
 		Channel:   event.Channel,
@@ -177,6 +229,8 @@ func (b *Bot) sendReport(event *slack.MessageEvent, teamName string) {
 	tc, err := b.scrum.GetTeamByName(teamName)
 	if err != nil {
 		b.logSlackRelatedError(event, err, "can't get team")
+		b.reply(event, slack.MsgOptionText("I don't know team "+teamName, true), slack.MsgOptionAsUser(true))
+		return
 	}
 
 	b.scrum.SendReportForTeam(tc, tc.Channel)
@@ -186,11 +240,129 @@ func (b *Bot) sendReportDm(event *slack.MessageEvent, teamName, sendTo string) {
 	tc, err := b.scrum.GetTeamByName(teamName)
 	if err != nil {
 		b.logSlackRelatedError(event, err, "can't get team")
+		b.reply(event, slack.MsgOptionText("I don't know team "+teamName, true), slack.MsgOptionAsUser(true))
+		return
 	}
 
 	b.scrum.SendReportForTeam(tc, sendTo)
 }
 
+func (b *Bot) sendDigestDm(event *slack.MessageEvent, teamName, sendTo string) {
+	tc, err := b.scrum.GetTeamByName(teamName)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "can't get team")
+		return
+	}
+
+	if err := b.scrum.SendDigestForTeam(tc, sendTo); err != nil {
+		b.logSlackRelatedError(event, err, "Fail to send digest.")
+	}
+}
+
+func (b *Bot) resolveBlockers(event *slack.MessageEvent, id string) {
+	params := slack.MsgOptionAsUser(true)
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return
+	}
+
+	username := user.Profile.DisplayName
+	tc := b.scrum.GetTeamForUser(username)
+	if tc == nil {
+		b.reply(event, slack.MsgOptionText("You're not part of a team, there are no blockers to resolve", true), params)
+		return
+	}
+
+	resolved, err := b.scrum.ResolveBlockers(tc, username, id)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to resolve blockers.")
+		return
+	}
+
+	if len(resolved) == 0 {
+		msg := "You don't have any open blockers"
+		if id != "" {
+			msg = "There is no open blocker `" + id + "` in team " + tc.Name
+		}
+		b.reply(event, slack.MsgOptionText(msg, true), params)
+		return
+	}
+
+	lines := []string{}
+	slackIDs := map[string]string{}
+	for _, blocker := range resolved {
+		if _, ok := slackIDs[blocker.User]; !ok {
+			slackIDs[blocker.User] = b.scrum.GetUserState(blocker.User).SlackID
+		}
+		lines = append(lines, fmt.Sprintf("- `%s` %s: %s", blocker.ID, report.Mention(blocker.User, slackIDs[blocker.User]), blocker.Text))
+	}
+	b.reply(event, slack.MsgOptionText(":white_check_mark: Resolved:\n"+strings.Join(lines, "\n"), false), params)
+}
+
+func (b *Bot) status(event *slack.MessageEvent) {
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return
+	}
+
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	tc := b.scrum.GetTeamForUser(us.User)
+	if tc == nil {
+		b.reply(event, slack.MsgOptionText("You're not part of a team", true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	now := time.Now()
+	today, err := tc.DayFor(us, now)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get current date.")
+		return
+	}
+
+	var msg string
+	switch {
+	case us.OutOfOffice:
+		msg = "You're out of office, tell me `i'm back` when you return."
+	case us.LastAnswerDate == today && us.Skipped:
+		msg = fmt.Sprintf("You skipped your scrum for %s.", today)
+	case us.HasReported(today, tc):
+		msg = fmt.Sprintf("You've handed in your scrum for %s :white_check_mark:", today)
+	case us.LastAnswerDate == today && us.Started:
+		msg = fmt.Sprintf("You've answered %d questions of your scrum for %s, tell me the next answer in a direct message.", len(us.Answers), today)
+	default:
+		msg = fmt.Sprintf("You haven't started your scrum for %s yet, tell me `start` in a direct message.", today)
+	}
+
+	next, err := tc.NextSlot(scrum.ReportEvent, tc.TimezoneFor(us), now)
+	if err == nil && !next.IsZero() {
+		msg += fmt.Sprintf(" The report of team %s goes out %s.", tc.Name, next.Format("Mon 15:04 MST"))
+	}
+	b.reply(event, slack.MsgOptionText(msg, true), slack.MsgOptionAsUser(true))
+}
+
+func (b *Bot) moodPrivacy(event *slack.MessageEvent, private bool) {
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return
+	}
+
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	us.MoodPrivate = private
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logSlackRelatedError(event, err, "Fail to save user information.")
+		return
+	}
+
+	msg := "Your mood will be shown in your scrum report"
+	if private {
+		msg = "Your mood will only count towards the team's mood, it won't be shown in your scrum report"
+	}
+	b.reply(event, slack.MsgOptionText(msg, true), slack.MsgOptionAsUser(true))
+}
+
 func (b *Bot) githubUser(event *slack.MessageEvent, githubUser string) {
 	user, err := b.slackBotAPI.GetUserInfo(event.User)
 	if err != nil {
@@ -225,7 +397,7 @@ func (b *Bot) handleGitHubEvent(event *slack.MessageEvent) {
 }
 
 func (b *Bot) sourceCode(event *slack.MessageEvent) {
-	_, _, err := b.slackBotAPI.PostMessage(event.Channel,
+	_, _, err := b.reply(event,
 		slack.MsgOptionText("My source code is here <https://github.com/asalkeld/scrumpolice>", true),
 		slack.MsgOptionAsUser(true))
 	if err != nil {
@@ -244,7 +416,7 @@ func (b *Bot) teamlist(event *slack.MessageEvent) {
 	for _, team := range teams {
 		output = append(output, team.Name)
 	}
-	_, _, err = b.slackBotAPI.PostMessage(event.Channel,
+	_, _, err = b.reply(event,
 		slack.MsgOptionText("The configured teams are: "+strings.Join(output, ", "), true),
 		slack.MsgOptionAsUser(true))
 	if err != nil {
@@ -252,25 +424,24 @@ func (b *Bot) teamlist(event *slack.MessageEvent) {
 	}
 }
 
-func (b *Bot) help(event *slack.MessageEvent) {
+func (b *Bot) help(event *slack.MessageEvent, command string) {
+	text := "Here's a list of supported commands, `help <command>` tells more about one"
 	message := slack.Attachment{
 		MarkdownIn: []string{"text"},
-		Text: "- `source code`: location of my source code\n" +
-			"- `help`: well, this command\n" +
-			"- `tutorial`: explains how the scrum police works. Try it!\n" +
-			"- `start`: starts a scrum for a team and a specific set of questions, defaults to your only team if you got only one, and only questions set if there's only one on the team you chose\n" +
-			"- `restart`: restart your last done scrum, if it wasn't posted\n" +
-			"- `teamlist`: list the available teams\n" +
-			"- `report <teamName>`: send the report to the configured channel\n" +
-			"- `report-dm <teamName>`: scrumpolice will direct message you the report to check\n" +
-			"- `github-user <github username>`: scrumpolice will gather github activity for your report\n" +
-			"- `out of office`: mark current user as out of office (until `i'm back` is used)\n" +
-			"- `[user] is out of office`: mark the specified user as out of office (until he or she uses `i'm back`)\n" +
-			"- `i am back` or `i'm back`: mark current user as in office. MacOS smart quote can screw up with the `i'm back` command.",
-	}
-
-	_, _, err := b.slackBotAPI.PostMessage(event.Channel,
-		slack.MsgOptionText("Here's a list of supported commands", true),
+		Text:       b.commands.Help(),
+	}
+	if command != "" {
+		cmd := b.commands.Get(command)
+		if cmd == nil {
+			b.reply(event, slack.MsgOptionText("I don't know the `"+command+"` command, try `help`", true), slack.MsgOptionAsUser(true))
+			return
+		}
+		text = "Here's how to use `" + cmd.Name + "`"
+		message.Text = cmd.Details()
+	}
+
+	_, _, err := b.reply(event,
+		slack.MsgOptionText(text, true),
 		slack.MsgOptionAsUser(true),
 		slack.MsgOptionAttachments(message))
 	if err != nil {
@@ -281,7 +452,7 @@ func (b *Bot) help(event *slack.MessageEvent) {
 
 // This method sleeps to give a better feeling to the user. It should be use in a sub-routine.
 func (b *Bot) tutorial(event *slack.MessageEvent) {
-	b.slackBotAPI.PostMessage(event.Channel,
+	b.reply(event,
 		slack.MsgOptionText("*Hi there* :wave: You want to know how I do things? Here :golang:es!\n"+
 			"When you want to start a scrum report, just tell me `start` in a direct message :flag-dm:.\n"+
 			"Then, I will ask you a couple of questions, and wait for your answers. Once you anwsered all the questions, you're done :white_check_mark:.\n"+
@@ -300,20 +471,20 @@ func (b *Bot) outOfOffice(event *slack.MessageEvent, userId string) {
 	b.scrum.AddToOutOfOffice(username)
 
 	if event.User == userId {
-		b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("I've marked you out of office in all your teams", true), params)
+		b.reply(event, slack.MsgOptionText("I've marked you out of office in all your teams", true), params)
 		log.WithFields(log.Fields{
 			"user":   username,
 			"doneBy": username,
 		}).Info("User was marked out of office.")
 	} else {
-		b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("I've marked @"+username+" out of office in all of your peer's teams", true), params)
+		b.reply(event, slack.MsgOptionText("I've marked @"+username+" out of office in all of your peer's teams", true), params)
 
 		user, err := b.slackBotAPI.GetUserInfo(event.User)
 		if err != nil {
 			b.logSlackRelatedError(event, err, "Fail to get user information.")
 			return
 		}
-		b.slackBotAPI.PostMessage("@"+username, slack.MsgOptionText("You've been marked out of office by @"+user.Name+".", true), params)
+		b.slackBotAPI.PostMessage("@"+username, slack.MsgOptionText("You've been marked out of office by <@"+user.ID+">.", true), params)
 		log.WithFields(log.Fields{
 			"user":   userId,
 			"doneBy": user.Name,
@@ -326,13 +497,13 @@ func (b *Bot) backInOffice(event *slack.MessageEvent) {
 	user, err := b.slackBotAPI.GetUserInfo(event.User)
 	if err != nil {
 		b.logSlackRelatedError(event, err, "Fail to get user information.")
-		b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("Hmmmm, I couldn't find you. Try again!", true), params)
+		b.reply(event, slack.MsgOptionText("Hmmmm, I couldn't find you. Try again!", true), params)
 		return
 	}
 	username := user.Name
 
 	b.scrum.RemoveFromOutOfOffice(username)
-	b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("I've marked you in office in all your teams. Welcome back!", true), params)
+	b.reply(event, slack.MsgOptionText("I've marked you in office in all your teams. Welcome back!", true), params)
 	log.WithFields(log.Fields{
 		"user":     event.User,
 		"username": event.Username,
@@ -347,7 +518,7 @@ func (b *Bot) unrecognizedMessage(event *slack.MessageEvent) {
 	}).Info("Received unrecognized message.")
 	params := slack.MsgOptionAsUser(true)
 
-	_, _, err := b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("I don't understand what you're trying to tell me, try `help`", true), params)
+	_, _, err := b.reply(event, slack.MsgOptionText("I don't understand what you're trying to tell me, try `help`", true), params)
 	if err != nil {
 		b.logSlackRelatedError(event, err, "Fail to post message to slack.")
 		return
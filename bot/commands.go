@@ -0,0 +1,340 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Scope is where a command can be used.
+type Scope int
+
+const (
+	// Anywhere is in a direct message or addressed to the bot in a channel
+	Anywhere Scope = iota
+	// DMOnly is in a direct message to the bot
+	DMOnly
+)
+
+// Permission is who can run a command.
+type Permission int
+
+const (
+	// Everyone can run the command
+	Everyone Permission = iota
+	// TeamMember needs the user to be in a team
+	TeamMember
+	// TeamAdmin needs the user to be the admin of the team named by the first argument,
+	// or one of its members if it has no admin
+	TeamAdmin
+)
+
+// Command is a chat command. Args is its syntax, `<arg>` is required and `[arg]` optional,
+// the last argument takes the rest of the message and `a|b` lists the accepted values.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Args        string
+	Description string
+	Scope       Scope
+	Permission  Permission
+	// DuringScrum commands are run even while the user is answering their scrum, others
+	// are taken as an answer
+	DuringScrum bool
+	Run         func(b *Bot, event *slack.MessageEvent, args []string)
+}
+
+// Usage is the command's syntax.
+func (c *Command) Usage() string {
+	if c.Args == "" {
+		return c.Name
+	}
+	return c.Name + " " + c.Args
+}
+
+var argRegex = regexp.MustCompile(`[<\[]([^>\]]+)[>\]]`)
+
+// arg is a parameter of a command's syntax.
+type arg struct {
+	name     string
+	required bool
+	choices  []string
+}
+
+func (c *Command) params() []arg {
+	params := []arg{}
+	for _, m := range argRegex.FindAllStringSubmatch(c.Args, -1) {
+		p := arg{name: m[1], required: strings.HasPrefix(m[0], "<")}
+		if strings.Contains(p.name, "|") {
+			p.choices = strings.Split(p.name, "|")
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// ParseArgs splits the words after the command in its arguments, the last one takes the
+// rest of the words. Optional arguments that weren't given are empty.
+func (c *Command) ParseArgs(words []string) ([]string, error) {
+	params := c.params()
+	if len(params) == 0 {
+		if len(words) > 0 {
+			return nil, fmt.Errorf("`%s` doesn't take any arguments", c.Name)
+		}
+		return []string{}, nil
+	}
+
+	args := make([]string, len(params))
+	for i, p := range params {
+		if i < len(words) {
+			args[i] = words[i]
+			if i == len(params)-1 {
+				args[i] = strings.Join(words[i:], " ")
+			}
+		}
+
+		if args[i] == "" {
+			if p.required {
+				return nil, fmt.Errorf("`%s` is missing", p.name)
+			}
+			continue
+		}
+		if len(p.choices) > 0 {
+			found := false
+			for _, choice := range p.choices {
+				if strings.EqualFold(choice, args[i]) {
+					args[i], found = choice, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("`%s` isn't one of %s", args[i], strings.Join(p.choices, ", "))
+			}
+		}
+	}
+	return args, nil
+}
+
+// Help is the command's line in the help.
+func (c *Command) Help() string {
+	line := fmt.Sprintf("- `%s`: %s", c.Usage(), c.Description)
+	if c.Scope == DMOnly {
+		line += " _(direct message only)_"
+	}
+	return line
+}
+
+// Details is the command's help, with its aliases and who can use it where.
+func (c *Command) Details() string {
+	lines := []string{"`" + c.Usage() + "`", c.Description}
+	if len(c.Aliases) > 0 {
+		lines = append(lines, "Also: `"+strings.Join(c.Aliases, "`, `")+"`")
+	}
+	switch c.Scope {
+	case DMOnly:
+		lines = append(lines, "Works in a direct message to me.")
+	default:
+		lines = append(lines, "Works in a direct message to me or addressed to me in a channel.")
+	}
+	switch c.Permission {
+	case TeamMember:
+		lines = append(lines, "You have to be in a team.")
+	case TeamAdmin:
+		lines = append(lines, "You have to be the team's admin, or in the team if it has no admin.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Commands is a registry of commands, in the order they are listed in the help.
+type Commands struct {
+	commands []*Command
+}
+
+// Register adds the command to the registry.
+func (cs *Commands) Register(c *Command) {
+	cs.commands = append(cs.commands, c)
+}
+
+// All returns the registered commands.
+func (cs *Commands) All() []*Command {
+	return cs.commands
+}
+
+// Get returns the command by its name or one of its aliases, nil if there is none.
+func (cs *Commands) Get(name string) *Command {
+	c, words, _ := cs.Route(name)
+	if c == nil || len(words) > 0 {
+		return nil
+	}
+	return c
+}
+
+// Route finds the command the text starts with and returns it with the words after it, the
+// longest name wins so `report-dm` isn't taken for `report`. ok is false if there is none.
+func (cs *Commands) Route(text string) (*Command, []string, bool) {
+	fields := strings.Fields(text)
+
+	var found *Command
+	longest := 0
+	for _, c := range cs.commands {
+		for _, name := range append([]string{c.Name}, c.Aliases...) {
+			words := strings.Fields(name)
+			if len(words) <= longest || len(words) > len(fields) {
+				continue
+			}
+			matches := true
+			for i, w := range words {
+				if !strings.EqualFold(w, fields[i]) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				found, longest = c, len(words)
+			}
+		}
+	}
+	if found == nil {
+		return nil, nil, false
+	}
+	return found, fields[longest:], true
+}
+
+// Help lists the commands.
+func (cs *Commands) Help() string {
+	lines := []string{}
+	for _, c := range cs.commands {
+		lines = append(lines, c.Help())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newCommands registers the bot's commands.
+func newCommands() *Commands {
+	cs := &Commands{}
+	cs.Register(&Command{
+		Name:        "help",
+		Args:        "[command]",
+		Description: "this list, or how to use a command",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.help(event, args[0]) },
+	})
+	cs.Register(&Command{
+		Name:        "tutorial",
+		Description: "explains how the scrum police works. Try it!",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.tutorial(event) },
+	})
+	cs.Register(&Command{
+		Name:        "source code",
+		Description: "location of my source code",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.sourceCode(event) },
+	})
+	cs.Register(&Command{
+		Name:        "start",
+		Description: "starts your scrum, I'll ask you your team's questions one by one",
+		Scope:       DMOnly,
+		Permission:  TeamMember,
+		DuringScrum: true,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.startScrum(event, false) },
+	})
+	cs.Register(&Command{
+		Name:        "skip",
+		Description: "skips your scrum for today when you have nothing to declare",
+		Scope:       DMOnly,
+		Permission:  TeamMember,
+		DuringScrum: true,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.startScrum(event, true) },
+	})
+	cs.Register(&Command{
+		Name:        "restart",
+		Aliases:     []string{"quit"},
+		Description: "deletes your scrum for today so you can `start` again",
+		Scope:       DMOnly,
+		DuringScrum: true,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.restartScrum(event) },
+	})
+	cs.Register(&Command{
+		Name:        "edit",
+		Args:        "[question number]",
+		Description: "lists your answers for today, or asks a question again to change just its answer",
+		Scope:       DMOnly,
+		Permission:  TeamMember,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.editScrum(event, args[0]) },
+	})
+	cs.Register(&Command{
+		Name:        "status",
+		Description: "where your scrum for today is at and when the report goes out",
+		Permission:  TeamMember,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.status(event) },
+	})
+	cs.Register(&Command{
+		Name:        "teamlist",
+		Aliases:     []string{"teams"},
+		Description: "list the configured teams",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.teamlist(event) },
+	})
+	cs.Register(&Command{
+		Name:        "mood",
+		Args:        "<private|public>",
+		Description: "whether your mood is shown in your scrum report or only counts towards the team's",
+		Run: func(b *Bot, event *slack.MessageEvent, args []string) {
+			b.moodPrivacy(event, args[0] == "private")
+		},
+	})
+	cs.Register(&Command{
+		Name:        "resolve",
+		Args:        "[blocker id]",
+		Description: "resolve a blocker from the report, or all of your own blockers",
+		Permission:  TeamMember,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.resolveBlockers(event, args[0]) },
+	})
+	cs.Register(&Command{
+		Name:        "report",
+		Args:        "<team name>",
+		Description: "send the team's report to its channel now",
+		Permission:  TeamAdmin,
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.sendReport(event, args[0]) },
+	})
+	cs.Register(&Command{
+		Name:        "report-dm",
+		Args:        "<team name>",
+		Description: "direct message you the team's report as it stands, to check it",
+		Run: func(b *Bot, event *slack.MessageEvent, args []string) {
+			b.sendReportDm(event, args[0], "@"+event.User)
+		},
+	})
+	cs.Register(&Command{
+		Name:        "digest-dm",
+		Args:        "<team name>",
+		Description: "direct message you the team's weekly digest",
+		Run: func(b *Bot, event *slack.MessageEvent, args []string) {
+			b.sendDigestDm(event, args[0], "@"+event.User)
+		},
+	})
+	cs.Register(&Command{
+		Name:        "github-user",
+		Args:        "<github username>",
+		Description: "I'll gather your GitHub activity to get your scrum started",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.githubUser(event, args[0]) },
+	})
+	cs.Register(&Command{
+		Name:        "out of office",
+		Aliases:     []string{"ooo"},
+		Args:        "[user]",
+		Description: "mark you, or someone else, out of office until they say `i'm back`. `<user> is out of office` works too",
+		Run: func(b *Bot, event *slack.MessageEvent, args []string) {
+			if args[0] == "" {
+				b.outOfOffice(event, event.User)
+				return
+			}
+			b.outOfOffice(event, args[0])
+		},
+	})
+	cs.Register(&Command{
+		Name:        "i'm back",
+		Aliases:     []string{"i am back", "i’m back", "back"},
+		Description: "mark you in office again",
+		Run:         func(b *Bot, event *slack.MessageEvent, args []string) { b.backInOffice(event) },
+	})
+	return cs
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoutePicksTheLongestCommand(t *testing.T) {
+	cs := newCommands()
+
+	cmd, words, ok := cs.Route("Report-DM L337 team")
+	if !ok || cmd.Name != "report-dm" || strings.Join(words, " ") != "L337 team" {
+		t.Errorf("expected report-dm with the team, got %v %v", cmd, words)
+	}
+
+	cmd, words, ok = cs.Route("out of office gfreeman")
+	if !ok || cmd.Name != "out of office" || len(words) != 1 {
+		t.Errorf("expected out of office with a user, got %v %v", cmd, words)
+	}
+
+	if cmd := cs.Get("i am back"); cmd == nil || cmd.Name != "i'm back" {
+		t.Errorf("expected the alias to find i'm back, got %v", cmd)
+	}
+
+	if _, _, ok := cs.Route("what's up"); ok {
+		t.Errorf("expected no command")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cs := newCommands()
+
+	if args, err := cs.Get("report").ParseArgs([]string{"L337", "team"}); err != nil || args[0] != "L337 team" {
+		t.Errorf("expected the last argument to take the rest, got %v %v", args, err)
+	}
+	if _, err := cs.Get("report").ParseArgs(nil); err == nil {
+		t.Errorf("expected the team name to be required")
+	}
+	if args, err := cs.Get("resolve").ParseArgs(nil); err != nil || args[0] != "" {
+		t.Errorf("expected the blocker id to be optional, got %v %v", args, err)
+	}
+	if args, err := cs.Get("mood").ParseArgs([]string{"Private"}); err != nil || args[0] != "private" {
+		t.Errorf("expected private, got %v %v", args, err)
+	}
+	if _, err := cs.Get("mood").ParseArgs([]string{"secret"}); err == nil {
+		t.Errorf("expected secret to be refused")
+	}
+	if _, err := cs.Get("skip").ParseArgs([]string{"the", "meeting"}); err == nil {
+		t.Errorf("expected skip not to take arguments")
+	}
+}
+
+func TestHelpListsEveryCommand(t *testing.T) {
+	cs := newCommands()
+	help := cs.Help()
+	for _, cmd := range cs.All() {
+		if !strings.Contains(help, "`"+cmd.Usage()+"`") {
+			t.Errorf("expected %s in the help", cmd.Name)
+		}
+	}
+}
@@ -28,6 +28,12 @@ func (b *Bot) EventHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.
 			return httpResponse(ctx, "unauthorized", http.StatusUnauthorized)
 		}
 	*/
+	if requestHeader(ctx).Get("X-Slack-Retry-Num") != "" {
+		// Slack sends the event again when it wasn't answered in 3 seconds, it was handled
+		// the first time
+		return next(ctx)
+	}
+
 	eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(ctx.Request.Data()), slackevents.OptionNoVerifyToken())
 	if err != nil {
 		return httpResponse(ctx, "Internal server error", http.StatusInternalServerError)
@@ -78,6 +84,8 @@ func (b *Bot) EventHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.
 				}
 			}
 			b.handleMessage(&slack.MessageEvent{Msg: msg}, false)
+		case *slackevents.ReactionAddedEvent:
+			b.handleReaction(ev.User, ev.Item.Channel, ev.Reaction)
 		}
 	}
 	return next(ctx)
@@ -0,0 +1,23 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/asalkeld/scrumpolice/scrum"
+	"github.com/nitrictech/go-sdk/faas"
+)
+
+func TestEventHandlerIgnoresRetries(t *testing.T) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", Questions: []scrum.Question{{Text: "Yesterday?"}}}
+	b, fs, posted := testBot(t, tc, &scrum.UserState{User: "gfreeman", SlackID: "U123"})
+
+	body := `{"type": "event_callback", "event": {"type": "message", "channel": "D123", "channel_type": "im", "user": "U123", "text": "start"}}`
+	ctx := &faas.HttpContext{
+		Request:  &fakeRequest{data: []byte(body), headers: map[string][]string{"x-slack-retry-num": {"1"}}},
+		Response: &faas.HttpResponse{Status: 200, Headers: map[string][]string{}},
+	}
+	ctx, _ = b.EventHandler(ctx, func(ctx *faas.HttpContext) (*faas.HttpContext, error) { return ctx, nil })
+	if ctx.Response.Status != 200 || len(*posted) != 0 || fs.users["gfreeman"].Started {
+		t.Errorf("expected the retry to be acknowledged and ignored, got %d %v", ctx.Response.Status, *posted)
+	}
+}
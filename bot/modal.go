@@ -0,0 +1,268 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/scrum"
+	"github.com/nitrictech/go-sdk/faas"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// entryCallbackID identifies the scrum form's submissions
+	entryCallbackID = "scrum_entry"
+	// answerActionID is the action of every answer input, each question has its own block
+	answerActionID = "answer"
+)
+
+// questionBlockID is the block of the question's input in the scrum form.
+func questionBlockID(i int) string {
+	return fmt.Sprintf("q%d", i)
+}
+
+func plainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, text, true, false)
+}
+
+// answerElement is the input for the question, free text or a list of the accepted answers,
+// showing the draft answer if there is one.
+func answerElement(q *scrum.Question, draft string) slack.BlockElement {
+	if q.IsFreeText() {
+		input := slack.NewPlainTextInputBlockElement(nil, answerActionID)
+		input.Multiline = true
+		input.InitialValue = draft
+		return input
+	}
+
+	options := []*slack.OptionBlockObject{}
+	for _, a := range q.Answers() {
+		options = append(options, slack.NewOptionBlockObject(a, plainText(q.Format(a)), nil))
+	}
+	sel := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick an answer"), answerActionID, options...)
+	for _, o := range options {
+		if o.Value == draft {
+			sel.InitialOption = o
+		}
+	}
+	return sel
+}
+
+// entryModal is the scrum form, one input per question pre-filled with the draft answers and
+// with the hints under them. Follow-ups are optional since whether they apply depends on the
+// other answers.
+func entryModal(tc *scrum.TeamConfig, draft map[string]string, hints map[string]string) slack.ModalViewRequest {
+	blocks := []slack.Block{}
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		input := slack.NewInputBlock(questionBlockID(i), plainText(q.Text), answerElement(q, draft[q.Text]))
+		hint := []string{}
+		if q.When.IsSet() {
+			input.Optional = true
+			hint = append(hint, fmt.Sprintf("Only if you answered %s to %q", q.When.Answer, q.When.Question))
+		}
+		if hints[q.Text] != "" {
+			hint = append(hint, hints[q.Text])
+		}
+		if len(hint) > 0 {
+			input.Hint = plainText(strings.Join(hint, "\n"))
+		}
+		blocks = append(blocks, input)
+	}
+
+	return slack.ModalViewRequest{
+		Type:       slack.VTModal,
+		CallbackID: entryCallbackID,
+		Title:      plainText("Your scrum"),
+		Submit:     plainText("Submit"),
+		Close:      plainText("Cancel"),
+		Blocks:     slack.Blocks{BlockSet: blocks},
+	}
+}
+
+// plannedHints reminds the member of what they planned in their last report, for the
+// questions that follow up on a plan.
+func (b *Bot) plannedHints(tc *scrum.TeamConfig, us *scrum.UserState) map[string]string {
+	hints := map[string]string{}
+	for _, q := range tc.Questions {
+		if q.PlannedIn == "" {
+			continue
+		}
+		if date, plan := b.scrum.PreviousAnswer(tc, us, q.PlannedIn); plan != "" {
+			hints[q.Text] = fmt.Sprintf("On %s you planned: %s", date, plan)
+		}
+	}
+	return hints
+}
+
+// entryAnswers validates the form's answers like the ones given in a direct message, it
+// returns the errors by block ID if some are missing or invalid. Answers to follow-ups that
+// don't apply are dropped.
+func entryAnswers(tc *scrum.TeamConfig, values map[string]map[string]slack.BlockAction) (map[string]string, map[string]string) {
+	answers := map[string]string{}
+	errs := map[string]string{}
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		action := values[questionBlockID(i)][answerActionID]
+		value := action.Value
+		if action.SelectedOption.Value != "" {
+			value = action.SelectedOption.Value
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		answer, err := q.Parse(value)
+		if err != nil {
+			errs[questionBlockID(i)] = fmt.Sprintf("Sorry, %s", err)
+			continue
+		}
+		answers[q.Text] = answer
+	}
+
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		_, answered := answers[q.Text]
+		if !tc.Applies(q, answers) {
+			delete(answers, q.Text)
+		} else if !answered && errs[questionBlockID(i)] == "" {
+			errs[questionBlockID(i)] = "Please answer this question"
+		}
+	}
+	return answers, errs
+}
+
+// InteractionHandler handles the Block Kit interactions, the "Fill in standup" button opens
+// the scrum form and submitting it saves the entry like answering in a direct message does.
+func (b *Bot) InteractionHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error) {
+	if err := b.verifySignature(ctx); err != nil {
+		b.logger.WithError(err).Warn("Refused an interaction")
+		return httpResponse(ctx, "unauthorized", http.StatusUnauthorized)
+	}
+
+	form, err := url.ParseQuery(string(ctx.Request.Data()))
+	if err != nil {
+		return httpResponse(ctx, "Bad request", http.StatusBadRequest)
+	}
+	callback := slack.InteractionCallback{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		return httpResponse(ctx, "Bad request", http.StatusBadRequest)
+	}
+
+	ctx.Response.Status = http.StatusOK
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		for _, action := range callback.ActionCallback.BlockActions {
+			if action.ActionID == scrum.FillInAction {
+				b.openEntryModal(callback.TriggerID, callback.User.ID)
+			}
+		}
+	case slack.InteractionTypeViewSubmission:
+		if callback.View.CallbackID != entryCallbackID {
+			break
+		}
+		if errs := b.submitEntryModal(callback.User.ID, callback.View.State); len(errs) > 0 {
+			body, err := json.Marshal(slack.NewErrorsViewSubmissionResponse(errs))
+			if err != nil {
+				return httpResponse(ctx, "Internal server error", http.StatusInternalServerError)
+			}
+			ctx.Response.Body = body
+			ctx.Response.Headers["Content-Type"] = []string{"application/json"}
+			return ctx, nil
+		}
+	}
+	return next(ctx)
+}
+
+// entryState returns the member's state and team, nil if they aren't in a team.
+func (b *Bot) entryState(userID string) (*scrum.UserState, *scrum.TeamConfig, error) {
+	user, err := b.slackBotAPI.GetUserInfo(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	if user.TZ != "" {
+		us.Timezone = user.TZ
+	}
+	us.SlackID = user.ID
+	return us, b.scrum.GetTeamForUser(us.User), nil
+}
+
+// openEntryModal opens the scrum form, with today's answers so far as the draft.
+func (b *Bot) openEntryModal(triggerID, userID string) {
+	us, tc, err := b.entryState(userID)
+	if err != nil {
+		b.logger.WithError(err).Error("Fail to get user information.")
+		return
+	}
+	if tc == nil {
+		b.slackBotAPI.PostMessage("@"+userID, slack.MsgOptionText("You're not part of a team, no point in doing a scrum report", true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	draft := map[string]string{}
+	if today, err := tc.DayFor(us, time.Now()); err == nil && us.LastAnswerDate == today && !us.Skipped {
+		draft = us.Answers
+	}
+	if _, err := b.slackBotAPI.OpenView(triggerID, entryModal(tc, draft, b.plannedHints(tc, us))); err != nil {
+		b.logger.WithError(err).Error("Fail to open the scrum form.")
+	}
+}
+
+// submitEntryModal saves the form's answers as the member's entry for today, it returns the
+// errors to show in the form if the answers aren't valid.
+func (b *Bot) submitEntryModal(userID string, state *slack.ViewState) map[string]string {
+	us, tc, err := b.entryState(userID)
+	if err != nil {
+		b.logger.WithError(err).Error("Fail to get user information.")
+		return nil
+	}
+	if tc == nil || state == nil {
+		return nil
+	}
+
+	answers, errs := entryAnswers(tc, state.Values)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	today, err := tc.DayFor(us, time.Now())
+	if err != nil {
+		b.logger.WithError(err).Error("Fail to get current date.")
+		return nil
+	}
+	us.Answers = answers
+	us.LastAnswerDate = today
+	us.Started = false
+	us.Skipped = false
+	us.Editing = ""
+	us.EditingDate = ""
+	us.Draft = ""
+	us.DraftAt = ""
+	us.SubmittedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logger.WithError(err).Error("Fail to save userState.")
+		return nil
+	}
+
+	// the form has to be answered within 3 seconds, the reports can take longer
+	b.publish(job{Kind: entryJob, UserID: userID})
+	return nil
+}
+
+// entryDone thanks the member for the entry they submitted with the form and sends it on.
+func (b *Bot) entryDone(userID string) {
+	us, tc, err := b.entryState(userID)
+	if err != nil {
+		b.logger.WithError(err).Error("Fail to get user information.")
+		return
+	}
+	b.slackBotAPI.PostMessage("@"+userID, slack.MsgOptionText(entrySavedMessage, true), slack.MsgOptionAsUser(true))
+	if tc != nil {
+		b.entrySubmitted(tc, us)
+	}
+}
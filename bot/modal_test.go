@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/asalkeld/scrumpolice/scrum"
+	"github.com/slack-go/slack"
+)
+
+func TestEntryAnswersValidatesTheForm(t *testing.T) {
+	tc := &scrum.TeamConfig{Questions: []scrum.Question{
+		{Text: "What did you do yesterday?"},
+		{Text: "Are you blocked?", Type: scrum.YesNoQuestion},
+		{Text: "By what?", When: scrum.Condition{Question: "Are you blocked?", Answer: "yes"}},
+		{Text: "How do you feel?", Type: scrum.ScaleQuestion},
+	}}
+	text := func(v string) map[string]slack.BlockAction {
+		return map[string]slack.BlockAction{answerActionID: {Value: v}}
+	}
+	selected := func(v string) map[string]slack.BlockAction {
+		return map[string]slack.BlockAction{answerActionID: {SelectedOption: slack.OptionBlockObject{Value: v}}}
+	}
+
+	answers, errs := entryAnswers(tc, map[string]map[string]slack.BlockAction{
+		"q0": text("Fixed the portal"),
+		"q1": selected("no"),
+		"q2": text("Nothing"),
+		"q3": selected("4"),
+	})
+	if len(errs) > 0 || len(answers) != 3 || answers["Are you blocked?"] != "no" || answers["How do you feel?"] != "4" {
+		t.Errorf("expected the follow-up to be dropped, got %v %v", answers, errs)
+	}
+
+	_, errs = entryAnswers(tc, map[string]map[string]slack.BlockAction{
+		"q0": text("Fixed the portal"),
+		"q1": selected("yes"),
+		"q3": selected("6"),
+	})
+	if errs["q2"] == "" || errs["q3"] == "" || len(errs) != 2 {
+		t.Errorf("expected the follow-up to be required and the scale refused, got %v", errs)
+	}
+}
+
+func TestEntryModalOffersTheAnswersAndHints(t *testing.T) {
+	tc := &scrum.TeamConfig{Questions: []scrum.Question{
+		{Text: "What did you do yesterday?", PlannedIn: "What will you do today?"},
+		{Text: "How do you feel?", Type: scrum.ScaleQuestion},
+	}}
+
+	modal := entryModal(tc, map[string]string{"How do you feel?": "4"}, map[string]string{"What did you do yesterday?": "On 2022-03-18 you planned: the portal"})
+	blocks := modal.Blocks.BlockSet
+	if hint := blocks[0].(*slack.InputBlock).Hint; hint == nil || hint.Text != "On 2022-03-18 you planned: the portal" {
+		t.Errorf("expected the plan as the hint, got %+v", hint)
+	}
+
+	sel := blocks[1].(*slack.InputBlock).Element.(*slack.SelectBlockElement)
+	if len(sel.Options) != 5 || sel.Options[0].Value != "1" || sel.InitialOption == nil || sel.InitialOption.Value != "4" {
+		t.Errorf("expected the scale with the draft selected, got %+v", sel)
+	}
+}
@@ -2,41 +2,25 @@ package bot
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/asalkeld/scrumpolice/common"
 	"github.com/asalkeld/scrumpolice/scrum"
 	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
 )
 
-// HandleMessage handle a received message for scrums and returns if the bot shall continue to process the message or stop
+// HandleScrumMessage takes a direct message as the answer to the user's current question if
+// they are answering their scrum, and returns if the bot shall continue to process the message or stop
 // continue = true
 // stop = false
 func (b *Bot) HandleScrumMessage(event *slack.MessageEvent) bool {
-	// "start [team] [date]"
-	// [team == first and only team]
-	// [date == yesterday]
-	// starting scrum for team [team] date [date]. if you want to abort say quit
-
 	// this module only takes case in private messages
 	if event.Channel[0] != 'D' {
 		return true
 	}
 
-	if strings.HasPrefix(strings.ToLower(event.Text), "start") {
-		return b.startScrum(event, false)
-	}
-
-	if strings.HasPrefix(strings.ToLower(event.Text), "skip") {
-		return b.startScrum(event, true)
-	}
-
-	if strings.ToLower(event.Text) == "restart" || strings.ToLower(event.Text) == "quit" {
-		return b.restartScrum(event)
-	}
-
 	return b.continueAnsweringQuestions(event)
 }
 
@@ -50,15 +34,19 @@ func (b *Bot) restartScrum(event *slack.MessageEvent) bool {
 	us := b.scrum.GetUserState(user.Profile.DisplayName)
 	tc := b.scrum.GetTeamForUser(us.User)
 	if tc == nil {
-		b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("You're not part of a team, no point in doing a scrum report", true), slack.MsgOptionAsUser(true))
+		b.reply(event, slack.MsgOptionText("You're not part of a team, no point in doing a scrum report", true), slack.MsgOptionAsUser(true))
 		return false
 	}
 	us.Answers = map[string]string{}
 	us.LastAnswerDate = ""
 	us.Started = false
+	us.Editing = ""
+	us.EditingDate = ""
+	us.Draft = ""
+	us.DraftAt = ""
 	b.scrum.SaveUserState(us)
 
-	b.slackBotAPI.PostMessage(event.Channel, slack.MsgOptionText("Your last report was deleted, you can `start` a new one again", true), slack.MsgOptionAsUser(true))
+	b.reply(event, slack.MsgOptionText("Your last report was deleted, you can `start` a new one again", true), slack.MsgOptionAsUser(true))
 	return false
 }
 
@@ -139,17 +127,27 @@ func (b *Bot) startScrum(event *slack.MessageEvent, isSkipped bool) bool {
 		return false
 	}
 
-	today, err := common.ToDay(tc.Timezone)
+	if user.TZ != "" {
+		us.Timezone = user.TZ
+	}
+	us.SlackID = user.ID
+
+	today, err := tc.DayFor(us, time.Now())
 	if err != nil {
 		b.logSlackRelatedError(event, err, "Fail to get current date.")
 		return false
 	}
 
+	us.Editing = ""
+	us.EditingDate = ""
+	us.Draft = ""
+	us.DraftAt = ""
 	if isSkipped {
 		us.Answers = map[string]string{}
 		us.LastAnswerDate = today
 		us.Started = false
 		us.Skipped = true
+		us.SubmittedAt = time.Now().UTC().Format(time.RFC3339)
 
 		err = b.scrum.SaveUserState(us)
 		if err != nil {
@@ -159,6 +157,11 @@ func (b *Bot) startScrum(event *slack.MessageEvent, isSkipped bool) bool {
 
 		msg := fmt.Sprintf("Scrum report skipped for %s in team %s, type `restart` if it should not be skipped", us.User, tc.Name)
 		b.slackBotAPI.PostMessage("@"+event.User, slack.MsgOptionText(msg, true), slack.MsgOptionAsUser(true))
+
+		b.scrum.EntrySubmitted(tc, us)
+		if err := b.scrum.SendLateEntry(tc, us); err != nil {
+			b.logSlackRelatedError(event, err, "Fail to send late entry.")
+		}
 		return false
 	}
 
@@ -177,9 +180,9 @@ func (b *Bot) startScrum(event *slack.MessageEvent, isSkipped bool) bool {
 
 	if us.GithubUser != "" {
 		now := time.Now()
-		loc, err := time.LoadLocation(strings.TrimSpace(tc.Timezone))
+		loc, err := time.LoadLocation(strings.TrimSpace(tc.TimezoneFor(us)))
 		if err != nil {
-			fmt.Println(err, "Failed to load timezone ", tc.Timezone)
+			fmt.Println(err, "Failed to load timezone ", tc.TimezoneFor(us))
 			now.Add(-10 * time.Hour) // temp hack
 		} else {
 			now = now.In(loc)
@@ -207,33 +210,61 @@ func (b *Bot) startScrum(event *slack.MessageEvent, isSkipped bool) bool {
 }
 
 func (b *Bot) answerQuestions(event *slack.MessageEvent, us *scrum.UserState, tc *scrum.TeamConfig) bool {
-	if len(us.Answers) == len(tc.Questions) {
+	qu := tc.NextQuestion(us)
+	if qu == nil {
+		us.SubmittedAt = time.Now().UTC().Format(time.RFC3339)
 		b.scrum.SaveUserState(us)
-		b.slackBotAPI.PostMessage("@"+event.User,
-			slack.MsgOptionText("Thanks for your scrum report my :deer:! :bear: with us for the digest. :owl: see you later!\n If you want to start again just say `restart`", true),
-			slack.MsgOptionAsUser(true))
-		b.logger.WithFields(log.Fields{
-			"user": us.User,
-			"team": tc.Name,
-		}).Info("All questions anwsered, entry saved.")
-
-		if err := b.scrum.RunReports(); err != nil {
-			b.logger.Error(err)
-		}
-
+		b.slackBotAPI.PostMessage("@"+event.User, slack.MsgOptionText(entrySavedMessage, true), slack.MsgOptionAsUser(true))
+		b.entrySubmitted(tc, us)
 		return false
 	}
 
-	for _, qu := range tc.Questions {
-		if _, answered := us.Answers[qu]; !answered {
-			b.slackBotAPI.PostMessage("@"+event.User, slack.MsgOptionText(qu, true), slack.MsgOptionAsUser(true))
-			break
-		}
+	prompt := b.prompt(tc, us, qu)
+	if tc.MultiMessageAnswers && qu.IsFreeText() {
+		prompt += fmt.Sprintf("\n_Take as many messages as you need, then say `next` or react with :%s:_", tc.DoneEmoji())
 	}
+	b.slackBotAPI.PostMessage("@"+event.User, slack.MsgOptionText(prompt, true), slack.MsgOptionAsUser(true))
 
 	return false
 }
 
+const entrySavedMessage = "Thanks for your scrum report my :deer:! :bear: with us for the digest. :owl: see you later!\n If you want to start again just say `restart`"
+
+// entrySubmitted notifies the webhooks of the member's entry, sends it on if the report already
+// went out, and runs the reports that are due.
+func (b *Bot) entrySubmitted(tc *scrum.TeamConfig, us *scrum.UserState) {
+	b.logger.WithFields(log.Fields{
+		"user": us.User,
+		"team": tc.Name,
+	}).Info("All questions anwsered, entry saved.")
+
+	b.scrum.EntrySubmitted(tc, us)
+	if err := b.scrum.SendLateEntry(tc, us); err != nil {
+		b.logger.WithFields(log.Fields{
+			"user":  us.User,
+			"team":  tc.Name,
+			"error": err,
+		}).Error("Fail to send late entry.")
+	}
+
+	if err := b.scrum.RunReports(); err != nil {
+		b.logger.Error(err)
+	}
+}
+
+// prompt asks the question, reminding the member of what they planned if it follows up on a plan.
+func (b *Bot) prompt(tc *scrum.TeamConfig, us *scrum.UserState, qu *scrum.Question) string {
+	if qu.PlannedIn == "" {
+		return qu.Prompt()
+	}
+
+	date, plan := b.scrum.PreviousAnswer(tc, us, qu.PlannedIn)
+	if plan == "" {
+		return qu.Prompt()
+	}
+	return fmt.Sprintf("%s\nOn %s you planned:\n>%s", qu.Prompt(), date, strings.ReplaceAll(plan, "\n", "\n>"))
+}
+
 func (b *Bot) continueAnsweringQuestions(event *slack.MessageEvent) bool {
 	user, err := b.slackBotAPI.GetUserInfo(event.User)
 	if err != nil {
@@ -242,11 +273,19 @@ func (b *Bot) continueAnsweringQuestions(event *slack.MessageEvent) bool {
 	}
 
 	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	tc := b.scrum.GetTeamForUser(us.User)
+	if b.editing(us, tc) {
+		if b.isCommand(event.Text) && !takesAnswer(editedQuestion(tc, us), event.Text) {
+			// the commands that can't be the new answer still work, e.g. `edit 2` to edit
+			// another answer
+			return true
+		}
+		return b.answerEdit(event, us)
+	}
 	if !us.Started {
 		return true
 	}
 
-	tc := b.scrum.GetTeamForUser(us.User)
 	if tc == nil {
 		b.slackBotAPI.PostMessage("@"+event.User,
 			slack.MsgOptionText("You're not part of a team, no point in doing a scrum report", true),
@@ -254,21 +293,282 @@ func (b *Bot) continueAnsweringQuestions(event *slack.MessageEvent) bool {
 		return false
 	}
 
-	if len(us.Answers) == len(tc.Questions) {
+	if us.Answers == nil {
+		us.Answers = map[string]string{}
+	}
+
+	qu := tc.NextQuestion(us)
+	if qu == nil {
 		return true
 	}
 
-	if us.Answers == nil {
-		us.Answers = map[string]string{}
+	if tc.MultiMessageAnswers && qu.IsFreeText() {
+		return b.draftAnswer(event, us, tc)
 	}
 
-	for _, qu := range tc.Questions {
-		if _, answered := us.Answers[qu]; !answered {
-			us.Answers[qu] = event.Text
-			b.scrum.SaveUserState(us)
-			break
+	answer, err := qu.Parse(event.Text)
+	if err != nil {
+		// ask again
+		b.slackBotAPI.PostMessage("@"+event.User,
+			slack.MsgOptionText(fmt.Sprintf("Sorry, %s.\n%s", err, b.prompt(tc, us, qu)), true),
+			slack.MsgOptionAsUser(true))
+		return false
+	}
+	us.Answers[qu.Text] = answer
+	b.scrum.SaveUserState(us)
+
+	return b.answerQuestions(event, us, tc)
+}
+
+// editScrum lists the member's answers for today, numbered, or asks the numbered question
+// again so its answer can be replaced without starting over.
+func (b *Bot) editScrum(event *slack.MessageEvent, number string) {
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return
+	}
+
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	tc := b.scrum.GetTeamForUser(us.User)
+	if tc == nil {
+		b.reply(event, slack.MsgOptionText("You're not part of a team, no point in doing a scrum report", true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	today, err := tc.DayFor(us, time.Now())
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get current date.")
+		return
+	}
+	if us.Started {
+		b.reply(event, slack.MsgOptionText("You're still answering your scrum, finish it first or type `restart` to start over", true), slack.MsgOptionAsUser(true))
+		return
+	}
+	questions := tc.Answered(us.Answers)
+	if us.LastAnswerDate != today || us.Skipped || len(questions) == 0 {
+		b.reply(event, slack.MsgOptionText("You haven't handed in your scrum for today, tell me `start` to fill it in", true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	if number == "" {
+		lines := []string{"Your answers for today:"}
+		for i, q := range questions {
+			lines = append(lines, fmt.Sprintf("%d. *%s*\n>%s", i+1, q.Text, strings.ReplaceAll(q.Format(us.Answers[q.Text]), "\n", "\n>")))
+		}
+		lines = append(lines, "Tell me `edit <number>` to change an answer.")
+		b.reply(event, slack.MsgOptionText(strings.Join(lines, "\n"), true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > len(questions) {
+		b.reply(event, slack.MsgOptionText(fmt.Sprintf("Sorry, please give the number of one of your %d answers, `edit` lists them", len(questions)), true), slack.MsgOptionAsUser(true))
+		return
+	}
+
+	qu := questions[n-1]
+	us.Editing = qu.Text
+	us.EditingDate = today
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logSlackRelatedError(event, err, "Fail to save userState.")
+		return
+	}
+	// the new answer comes in a direct message, even when the command was a slash command
+	b.slackBotAPI.PostMessage("@"+event.User,
+		slack.MsgOptionText(fmt.Sprintf("%s\nYou answered:\n>%s", b.prompt(tc, us, qu), strings.ReplaceAll(us.Answers[qu.Text], "\n", "\n>")), true),
+		slack.MsgOptionAsUser(true))
+}
+
+// editing tells if the member is editing an answer, an edit they left for another day is
+// dropped rather than taking that day's messages as the new answer.
+func (b *Bot) editing(us *scrum.UserState, tc *scrum.TeamConfig) bool {
+	if us.Editing == "" {
+		return false
+	}
+	if tc != nil {
+		if today, err := tc.DayFor(us, time.Now()); err == nil && us.EditingDate == today {
+			return true
 		}
 	}
 
+	us.Editing = ""
+	us.EditingDate = ""
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logger.WithError(err).Error("Fail to save userState.")
+	}
+	return false
+}
+
+// isCommand tells if the text is one of the commands, with valid arguments.
+func (b *Bot) isCommand(text string) bool {
+	cmd, words, found := b.commands.Route(strings.TrimSpace(text))
+	if !found {
+		return false
+	}
+	_, err := cmd.ParseArgs(words)
+	return err == nil
+}
+
+// editedQuestion returns the question the member is editing, nil if it isn't one of the
+// team's questions anymore.
+func editedQuestion(tc *scrum.TeamConfig, us *scrum.UserState) *scrum.Question {
+	if tc == nil {
+		return nil
+	}
+	for i := range tc.Questions {
+		if tc.Questions[i].Text == us.Editing {
+			return &tc.Questions[i]
+		}
+	}
+	return nil
+}
+
+// takesAnswer tells if the text is a valid answer to the question, any text is for a free
+// text question.
+func takesAnswer(qu *scrum.Question, text string) bool {
+	if qu == nil {
+		return false
+	}
+	_, err := qu.Parse(text)
+	return err == nil
+}
+
+// answersEdit tells if the direct message is the new answer to the question the member is
+// editing, a command word like `skip` is then the answer rather than the command.
+func (b *Bot) answersEdit(event *slack.MessageEvent) bool {
+	if event.Channel == "" || event.Channel[0] != 'D' {
+		return false
+	}
+	user, err := b.slackBotAPI.GetUserInfo(event.User)
+	if err != nil {
+		b.logSlackRelatedError(event, err, "Fail to get user information.")
+		return false
+	}
+
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	tc := b.scrum.GetTeamForUser(us.User)
+	return b.editing(us, tc) && takesAnswer(editedQuestion(tc, us), event.Text)
+}
+
+// answerEdit replaces the answer to the question being edited, asking any follow-up the new
+// answer calls for, and updates the entry in the report if it already went out.
+func (b *Bot) answerEdit(event *slack.MessageEvent, us *scrum.UserState) bool {
+	tc := b.scrum.GetTeamForUser(us.User)
+	qu := editedQuestion(tc, us)
+	if qu == nil {
+		// the question was removed from the team's configuration in the meantime
+		us.Editing = ""
+		us.EditingDate = ""
+		b.scrum.SaveUserState(us)
+		return true
+	}
+
+	answer, err := qu.Parse(event.Text)
+	if err != nil {
+		b.slackBotAPI.PostMessage("@"+event.User,
+			slack.MsgOptionText(fmt.Sprintf("Sorry, %s.\n%s", err, b.prompt(tc, us, qu)), true),
+			slack.MsgOptionAsUser(true))
+		return false
+	}
+	us.Answers[qu.Text] = answer
+	us.Editing = ""
+	us.EditingDate = ""
+	tc.DropInapplicable(us.Answers)
+
+	if tc.NextQuestion(us) != nil {
+		// the new answer calls for a follow-up
+		us.Started = true
+		b.scrum.SaveUserState(us)
+		return b.answerQuestions(event, us, tc)
+	}
+
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logSlackRelatedError(event, err, "Fail to save userState.")
+		return false
+	}
+	b.slackBotAPI.PostMessage("@"+event.User, slack.MsgOptionText("Got it, your answer is updated :pencil2:", true), slack.MsgOptionAsUser(true))
+	b.entrySubmitted(tc, us)
+	return false
+}
+
+// doneWords end an answer in multi-message mode.
+var doneWords = map[string]bool{"next": true, "done": true}
+
+// draftAnswer adds the message to the answer being written in multi-message mode. The answer
+// is done when the member says `next` or `done`, reacts with the team's done emoji, or stops
+// writing for the team's answer timeout.
+func (b *Bot) draftAnswer(event *slack.MessageEvent, us *scrum.UserState, tc *scrum.TeamConfig) bool {
+	text := strings.TrimSpace(event.Text)
+	if doneWords[strings.ToLower(strings.Trim(text, ".!"))] {
+		if strings.TrimSpace(us.Draft) == "" {
+			b.slackBotAPI.PostMessage("@"+event.User,
+				slack.MsgOptionText("You haven't written anything for this one yet.\n"+b.prompt(tc, us, tc.NextQuestion(us)), true),
+				slack.MsgOptionAsUser(true))
+			return false
+		}
+		return b.finishDraft(event, us, tc)
+	}
+
+	if us.Draft != "" {
+		us.Draft += "\n"
+	}
+	us.Draft += text
+	us.DraftAt = time.Now().UTC().Format(time.RFC3339)
+	if err := b.scrum.SaveUserState(us); err != nil {
+		b.logSlackRelatedError(event, err, "Fail to save userState.")
+		return false
+	}
+
+	return false
+}
+
+// finishDraft takes the draft as the answer to the current question and asks the next one.
+func (b *Bot) finishDraft(event *slack.MessageEvent, us *scrum.UserState, tc *scrum.TeamConfig) bool {
+	qu := tc.NextQuestion(us)
+	if qu == nil {
+		return true
+	}
+	if us.Answers == nil {
+		us.Answers = map[string]string{}
+	}
+	us.Answers[qu.Text] = us.Draft
+	us.Draft = ""
+	us.DraftAt = ""
+	b.scrum.SaveUserState(us)
+
 	return b.answerQuestions(event, us, tc)
 }
+
+const draftTimedOutMessage = "You stopped writing, I took what you wrote as your answer :pencil:"
+
+// draftTimedOut takes what the member wrote as their answer once they stopped writing, the
+// scheduler finds these drafts.
+func (b *Bot) draftTimedOut(tc *scrum.TeamConfig, us *scrum.UserState) {
+	if us.SlackID == "" {
+		return
+	}
+	b.slackBotAPI.PostMessage("@"+us.SlackID, slack.MsgOptionText(draftTimedOutMessage, true), slack.MsgOptionAsUser(true))
+	b.finishDraft(&slack.MessageEvent{Msg: slack.Msg{User: us.SlackID}}, us, tc)
+}
+
+// handleReaction ends the answer being written in multi-message mode when the member reacts
+// with the team's done emoji in their direct message with the bot.
+func (b *Bot) handleReaction(userID, channel, reaction string) {
+	if channel == "" || channel[0] != 'D' {
+		return
+	}
+
+	user, err := b.slackBotAPI.GetUserInfo(userID)
+	if err != nil {
+		b.logger.WithError(err).Error("Fail to get user information.")
+		return
+	}
+	us := b.scrum.GetUserState(user.Profile.DisplayName)
+	tc := b.scrum.GetTeamForUser(us.User)
+	if tc == nil || !tc.MultiMessageAnswers || reaction != tc.DoneEmoji() || !us.Started || us.Draft == "" {
+		return
+	}
+
+	b.finishDraft(&slack.MessageEvent{Msg: slack.Msg{User: userID, Channel: channel}}, us, tc)
+}
@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/scrum"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// fakeScrum keeps the user states in memory, the methods the tests don't need aren't implemented.
+type fakeScrum struct {
+	scrum.Service
+	team      *scrum.TeamConfig
+	users     map[string]*scrum.UserState
+	submitted []string
+	delivered []string
+}
+
+func (f *fakeScrum) GetTeamByName(team string) (*scrum.TeamConfig, error) {
+	if team != f.team.Name {
+		return nil, fmt.Errorf("no team %s", team)
+	}
+	return f.team, nil
+}
+func (f *fakeScrum) GetTeamForUser(username string) *scrum.TeamConfig { return f.team }
+func (f *fakeScrum) PreviousAnswer(tc *scrum.TeamConfig, us *scrum.UserState, question string) (string, string) {
+	return "", ""
+}
+func (f *fakeScrum) EntrySubmitted(tc *scrum.TeamConfig, us *scrum.UserState) {
+	f.submitted = append(f.submitted, us.User)
+}
+func (f *fakeScrum) SendLateEntry(tc *scrum.TeamConfig, us *scrum.UserState) error { return nil }
+func (f *fakeScrum) RunReports() error                                             { return nil }
+func (f *fakeScrum) DeliverWebhook(team, event string, data interface{}) error {
+	raw, _ := data.(json.RawMessage)
+	f.delivered = append(f.delivered, team+" "+event+" "+string(raw))
+	return nil
+}
+
+func (f *fakeScrum) GetUserState(username string) *scrum.UserState {
+	us, ok := f.users[username]
+	if !ok {
+		return &scrum.UserState{User: username, Answers: map[string]string{}}
+	}
+	cp := *us
+	cp.Answers = map[string]string{}
+	for q, a := range us.Answers {
+		cp.Answers[q] = a
+	}
+	return &cp
+}
+
+func (f *fakeScrum) SaveUserState(us *scrum.UserState) error {
+	cp := *us
+	f.users[us.User] = &cp
+	return nil
+}
+
+// fakeSlack answers the Slack API calls as user U123 "gfreeman", it returns the texts of the
+// messages posted.
+func fakeSlack(t *testing.T) (*slack.Client, *[]string) {
+	posted := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users.info":
+			w.Write([]byte(`{"ok": true, "user": {"id": "U123", "tz": "UTC", "profile": {"display_name": "gfreeman"}}}`))
+		case "/chat.postMessage":
+			r.ParseForm()
+			posted = append(posted, r.Form.Get("text"))
+			w.Write([]byte(`{"ok": true, "channel": "D123", "ts": "1"}`))
+		default:
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return slack.New("token", slack.OptionAPIURL(srv.URL+"/")), &posted
+}
+
+func testBot(t *testing.T, tc *scrum.TeamConfig, us *scrum.UserState) (*Bot, *fakeScrum, *[]string) {
+	api, posted := fakeSlack(t)
+	fs := &fakeScrum{team: tc, users: map[string]*scrum.UserState{us.User: us}}
+	return &Bot{slackBotAPI: api, scrum: fs, logger: log.New(), commands: newCommands()}, fs, posted
+}
+
+func dm(text string) *slack.MessageEvent {
+	return &slack.MessageEvent{Msg: slack.Msg{Channel: "D123", User: "U123", Text: text}}
+}
+
+func TestEditingStillRunsCommands(t *testing.T) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", Questions: []scrum.Question{{Text: "Yesterday?"}, {Text: "Deployed?", Type: scrum.YesNoQuestion}}}
+	today, _ := tc.DayIn("UTC", time.Now())
+	b, fs, posted := testBot(t, tc, &scrum.UserState{User: "gfreeman", LastAnswerDate: today, Editing: "Deployed?", EditingDate: today,
+		Answers: map[string]string{"Yesterday?": "a", "Deployed?": "no"}})
+
+	b.handleMessage(dm("edit"), true)
+	if us := fs.users["gfreeman"]; us.Answers["Deployed?"] != "no" || len(*posted) == 0 || !strings.Contains((*posted)[0], "Your answers for today") {
+		t.Errorf("expected `edit` to list the answers, got %+v %v", us, *posted)
+	}
+
+	b.handleMessage(dm("yes"), true)
+	if us := fs.users["gfreeman"]; us.Answers["Deployed?"] != "yes" || us.Editing != "" || len(fs.submitted) != 1 {
+		t.Errorf("expected the answer to be replaced, got %+v", us)
+	}
+}
+
+func TestEditingTakesCommandWordsAsTheAnswer(t *testing.T) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", Questions: []scrum.Question{{Text: "Yesterday?"}, {Text: "Today?"}}}
+	today, _ := tc.DayIn("UTC", time.Now())
+	for _, word := range []string{"help", "status", "skip", "i'm back"} {
+		b, fs, _ := testBot(t, tc, &scrum.UserState{User: "gfreeman", LastAnswerDate: today, Editing: "Today?", EditingDate: today,
+			Answers: map[string]string{"Yesterday?": "a", "Today?": "b"}})
+
+		b.handleMessage(dm(word), true)
+		if us := fs.users["gfreeman"]; us.Answers["Today?"] != word || us.Editing != "" || us.Skipped || len(fs.submitted) != 1 {
+			t.Errorf("expected %q to be the new answer, got %+v", word, us)
+		}
+	}
+}
+
+func TestEditingIsDroppedTheNextDay(t *testing.T) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", Questions: []scrum.Question{{Text: "Yesterday?"}, {Text: "Today?"}}}
+	b, fs, _ := testBot(t, tc, &scrum.UserState{User: "gfreeman", LastAnswerDate: "2022-03-20", Editing: "Today?", EditingDate: "2022-03-20",
+		Answers: map[string]string{"Yesterday?": "a", "Today?": "b"}})
+
+	b.handleMessage(dm("the portal"), true)
+	if us := fs.users["gfreeman"]; us.Answers["Today?"] != "b" || us.Editing != "" || len(fs.submitted) != 0 {
+		t.Errorf("expected yesterday's edit to be dropped, got %+v", us)
+	}
+}
+
+func draftingBot(t *testing.T, draft string) (*Bot, *fakeScrum, *[]string) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", MultiMessageAnswers: true, Questions: []scrum.Question{{Text: "Yesterday?"}, {Text: "Today?"}}}
+	today, _ := tc.DayIn("UTC", time.Now())
+	return testBot(t, tc, &scrum.UserState{User: "gfreeman", SlackID: "U123", Started: true, LastAnswerDate: today, Draft: draft, Answers: map[string]string{}})
+}
+
+func TestDraftAddsUpUntilNext(t *testing.T) {
+	b, fs, posted := draftingBot(t, "")
+
+	b.handleMessage(dm("fixed the portal"), true)
+	b.handleMessage(dm("and the lab"), true)
+	us := fs.users["gfreeman"]
+	if us.Draft != "fixed the portal\nand the lab" || us.DraftAt == "" || len(us.Answers) != 0 {
+		t.Errorf("expected the messages to add up, got %+v", us)
+	}
+
+	b.handleMessage(dm("Next"), true)
+	us = fs.users["gfreeman"]
+	if us.Answers["Yesterday?"] != "fixed the portal\nand the lab" || us.Draft != "" || us.DraftAt != "" {
+		t.Errorf("expected the draft to be the answer, got %+v", us)
+	}
+	if last := (*posted)[len(*posted)-1]; !strings.Contains(last, "Today?") {
+		t.Errorf("expected the next question, got %q", last)
+	}
+}
+
+func TestDoneWithoutADraftAsksAgain(t *testing.T) {
+	b, fs, posted := draftingBot(t, "")
+
+	b.handleMessage(dm("done"), true)
+	if us := fs.users["gfreeman"]; len(us.Answers) != 0 || len(*posted) != 1 || !strings.Contains((*posted)[0], "haven't written anything") {
+		t.Errorf("expected to be asked again, got %+v %v", us, *posted)
+	}
+}
+
+func TestReactionEndsTheDraft(t *testing.T) {
+	b, fs, _ := draftingBot(t, "fixed the portal")
+
+	b.handleReaction("U123", "D123", "thumbsup")
+	if us := fs.users["gfreeman"]; len(us.Answers) != 0 {
+		t.Errorf("expected another emoji to be ignored, got %+v", us)
+	}
+
+	b.handleReaction("U123", "D123", "white_check_mark")
+	if us := fs.users["gfreeman"]; us.Answers["Yesterday?"] != "fixed the portal" || us.Draft != "" {
+		t.Errorf("expected the draft to be the answer, got %+v", us)
+	}
+}
+
+func TestDraftTimedOutTakesTheDraft(t *testing.T) {
+	b, fs, posted := draftingBot(t, "fixed the portal")
+
+	b.draftTimedOut(fs.team, fs.GetUserState("gfreeman"))
+	if us := fs.users["gfreeman"]; us.Answers["Yesterday?"] != "fixed the portal" || len(*posted) != 2 || (*posted)[0] != draftTimedOutMessage {
+		t.Errorf("expected the draft to be the answer, got %+v %v", us, *posted)
+	}
+}
+
+func TestReportForAnUnknownTeam(t *testing.T) {
+	tc := &scrum.TeamConfig{Name: "L337", Timezone: "UTC", Questions: []scrum.Question{{Text: "Yesterday?"}}}
+	b, _, posted := testBot(t, tc, &scrum.UserState{User: "gfreeman", SlackID: "U123"})
+
+	b.handleMessage(dm("report-dm black-mesa"), true)
+	if len(*posted) != 1 || !strings.Contains((*posted)[0], "I don't know team black-mesa") {
+		t.Errorf("expected to be told the team is unknown, got %v", *posted)
+	}
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nitrictech/go-sdk/faas"
+	"github.com/slack-go/slack"
+)
+
+// requestHeader returns the request's headers, whatever the case of their names.
+func requestHeader(ctx *faas.HttpContext) http.Header {
+	header := http.Header{}
+	for k, values := range ctx.Request.Headers() {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+	return header
+}
+
+// verifySignature checks the request was signed by Slack with the app's signing secret.
+func (b *Bot) verifySignature(ctx *faas.HttpContext) error {
+	if b.signingSecret == "" {
+		return fmt.Errorf("no Slack signing secret is set")
+	}
+
+	sv, err := slack.NewSecretsVerifier(requestHeader(ctx), b.signingSecret)
+	if err != nil {
+		return err
+	}
+	if _, err := sv.Write(ctx.Request.Data()); err != nil {
+		return err
+	}
+	return sv.Ensure()
+}
+
+// ephemeralResponse answers the request with a message only the user sees.
+func ephemeralResponse(ctx *faas.HttpContext, text string) (*faas.HttpContext, error) {
+	body, err := json.Marshal(slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: text})
+	if err != nil {
+		return httpResponse(ctx, "Internal server error", http.StatusInternalServerError)
+	}
+	ctx.Response.Body = body
+	ctx.Response.Headers["Content-Type"] = []string{"application/json"}
+	return ctx, nil
+}
+
+// SlashHandler runs the `/scrum <command>` slash command. Slack wants an answer within 3
+// seconds so the command is acknowledged straight away and handed to the work topic, its
+// replies go to the command's response URL and only the user sees them.
+func (b *Bot) SlashHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error) {
+	if err := b.verifySignature(ctx); err != nil {
+		b.logger.WithError(err).Warn("Refused a slash command")
+		return httpResponse(ctx, "unauthorized", http.StatusUnauthorized)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/slash", bytes.NewReader(ctx.Request.Data()))
+	if err != nil {
+		return httpResponse(ctx, "Internal server error", http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	sc, err := slack.SlashCommandParse(req)
+	if err != nil {
+		return httpResponse(ctx, "Bad request", http.StatusBadRequest)
+	}
+
+	text := sc.Text
+	if text == "" {
+		text = "help"
+	}
+	if _, _, found := b.commands.Route(text); !found {
+		return ephemeralResponse(ctx, fmt.Sprintf("I don't understand `%s %s`, try `%s help`", sc.Command, sc.Text, sc.Command))
+	}
+
+	b.publish(job{
+		Kind:        slashJob,
+		UserID:      sc.UserID,
+		Channel:     sc.ChannelID,
+		Username:    sc.UserName,
+		Text:        text,
+		ResponseURL: sc.ResponseURL,
+	})
+
+	ctx.Response.Status = http.StatusOK
+	return next(ctx)
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nitrictech/go-sdk/api/events"
+	"github.com/nitrictech/go-sdk/faas"
+	log "github.com/sirupsen/logrus"
+)
+
+type fakeRequest struct {
+	data    []byte
+	headers map[string][]string
+}
+
+func (r *fakeRequest) Data() []byte                  { return r.data }
+func (r *fakeRequest) MimeType() string              { return "application/x-www-form-urlencoded" }
+func (r *fakeRequest) Method() string                { return "POST" }
+func (r *fakeRequest) Path() string                  { return "/slash" }
+func (r *fakeRequest) Query() map[string][]string    { return map[string][]string{} }
+func (r *fakeRequest) Headers() map[string][]string  { return r.headers }
+func (r *fakeRequest) PathParams() map[string]string { return map[string]string{} }
+
+type fakeTopic struct {
+	published []*events.Event
+}
+
+func (t *fakeTopic) Name() string { return WorkTopic }
+func (t *fakeTopic) Publish(evt *events.Event) (*events.Event, error) {
+	t.published = append(t.published, evt)
+	return evt, nil
+}
+
+func slashContext(secret, text string) *faas.HttpContext {
+	body := url.Values{"command": {"/scrum"}, "text": {text}, "user_id": {"U123"}}.Encode()
+	ts := fmt.Sprint(time.Now().Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	return &faas.HttpContext{
+		Request: &fakeRequest{
+			data: []byte(body),
+			headers: map[string][]string{
+				"x-slack-request-timestamp": {ts},
+				"x-slack-signature":         {"v0=" + hex.EncodeToString(mac.Sum(nil))},
+			},
+		},
+		Response: &faas.HttpResponse{Status: 200, Headers: map[string][]string{}},
+	}
+}
+
+func TestSlashVerifiesTheSignature(t *testing.T) {
+	b := &Bot{signingSecret: "secret", commands: newCommands(), logger: log.New()}
+
+	if err := b.verifySignature(slashContext("secret", "help")); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	ctx, _ := b.SlashHandler(slashContext("not the secret", "help"), nil)
+	if ctx.Response.Status != 401 {
+		t.Errorf("expected the request to be refused, got %d", ctx.Response.Status)
+	}
+}
+
+func TestSlashAnswersUnknownCommandsEphemerally(t *testing.T) {
+	b := &Bot{signingSecret: "secret", commands: newCommands(), logger: log.New()}
+
+	ctx, _ := b.SlashHandler(slashContext("secret", "dance"), nil)
+	if ctx.Response.Status != 200 || !strings.Contains(string(ctx.Response.Body), `"response_type":"ephemeral"`) {
+		t.Errorf("expected an ephemeral answer, got %d %s", ctx.Response.Status, ctx.Response.Body)
+	}
+}
+
+func TestSlashHandsTheCommandToTheWorkTopic(t *testing.T) {
+	work := &fakeTopic{}
+	b := &Bot{signingSecret: "secret", commands: newCommands(), logger: log.New(), work: work}
+
+	ctx, _ := b.SlashHandler(slashContext("secret", "status"), func(ctx *faas.HttpContext) (*faas.HttpContext, error) { return ctx, nil })
+	if ctx.Response.Status != 200 || len(work.published) != 1 {
+		t.Fatalf("expected the command to be published, got %d %v", ctx.Response.Status, work.published)
+	}
+
+	data, _ := json.Marshal(work.published[0].Payload)
+	j := job{}
+	if err := json.Unmarshal(data, &j); err != nil || j.Kind != slashJob || j.Text != "status" || j.UserID != "U123" {
+		t.Errorf("unexpected job %+v %v", j, err)
+	}
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"encoding/json"
+
+	"github.com/nitrictech/go-sdk/api/events"
+	"github.com/nitrictech/go-sdk/faas"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// WorkTopic carries the work the slash commands, the scrum form and the webhooks hand over.
+// Slack wants an answer within 3 seconds and nothing runs once the function has answered,
+// so the work is published and done by the topic's subscriber.
+const WorkTopic = "botWork"
+
+const (
+	// slashJob runs a slash command
+	slashJob = "slash"
+	// entryJob sends on the entry submitted with the scrum form
+	entryJob = "entry"
+	// webhookJob delivers an event to the team's webhooks
+	webhookJob = "webhook"
+)
+
+// job is the work left to do after answering Slack.
+type job struct {
+	Kind   string `json:"kind"`
+	UserID string `json:"userID"`
+	// the slash command's
+	Channel     string `json:"channel,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Text        string `json:"text,omitempty"`
+	ResponseURL string `json:"responseURL,omitempty"`
+	// the webhook event's
+	Team  string          `json:"team,omitempty"`
+	Event string          `json:"event,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// publish hands the job to the work topic, it is done straight away if there is no topic
+// or it can't be published.
+func (b *Bot) publish(j job) {
+	if b.work != nil {
+		payload := map[string]interface{}{}
+		data, err := json.Marshal(j)
+		if err == nil {
+			err = json.Unmarshal(data, &payload)
+		}
+		if err == nil {
+			_, err = b.work.Publish(&events.Event{PayloadType: j.Kind, Payload: payload})
+		}
+		if err == nil {
+			return
+		}
+		b.logger.WithError(err).Warn("Failed to publish the job, doing it now")
+	}
+	b.runJob(j)
+}
+
+// publishWebhook hands a webhook event to the work topic, the deliveries retry for longer
+// than Slack waits for the events that cause them.
+func (b *Bot) publishWebhook(team, event string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		b.logger.WithError(err).WithFields(log.Fields{"team": team, "event": event}).Error("Fail to encode the webhook event.")
+		return
+	}
+	b.publish(job{Kind: webhookJob, Team: team, Event: event, Data: raw})
+}
+
+// WorkHandler does the jobs published to the work topic.
+func (b *Bot) WorkHandler(ctx *faas.EventContext, next faas.EventHandler) (*faas.EventContext, error) {
+	j := job{}
+	if err := json.Unmarshal(ctx.Request.Data(), &j); err != nil {
+		b.logger.WithError(err).Error("Fail to read the job.")
+		return next(ctx)
+	}
+	b.runJob(j)
+	return next(ctx)
+}
+
+func (b *Bot) runJob(j job) {
+	switch j.Kind {
+	case slashJob:
+		cmd, words, found := b.commands.Route(j.Text)
+		if !found {
+			return
+		}
+		event := &slack.MessageEvent{Msg: slack.Msg{
+			Channel:  j.Channel,
+			User:     j.UserID,
+			Username: j.Username,
+			Text:     j.Text,
+		}}
+		b.responseURLs.Store(event, j.ResponseURL)
+		defer b.responseURLs.Delete(event)
+		args, err := cmd.ParseArgs(words)
+		// the replies are private so the slash command works like a direct message
+		b.runCommand(event, true, cmd, args, err)
+	case entryJob:
+		b.entryDone(j.UserID)
+	case webhookJob:
+		if err := b.scrum.DeliverWebhook(j.Team, j.Event, j.Data); err != nil {
+			b.logger.WithError(err).WithFields(log.Fields{"team": j.Team, "event": j.Event}).Warn("Failed to deliver the webhook event")
+		}
+	default:
+		b.logger.WithField("kind", j.Kind).Warn("Unknown job")
+	}
+}
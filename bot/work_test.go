@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/asalkeld/scrumpolice/scrum"
+	"github.com/asalkeld/scrumpolice/webhook"
+	"github.com/nitrictech/go-sdk/faas"
+)
+
+type fakeEventRequest struct {
+	data []byte
+}
+
+func (r *fakeEventRequest) Data() []byte     { return r.data }
+func (r *fakeEventRequest) MimeType() string { return "application/json" }
+func (r *fakeEventRequest) Topic() string    { return WorkTopic }
+
+func TestWebhookEventsGoThroughTheWorkTopic(t *testing.T) {
+	b, fs, _ := testBot(t, &scrum.TeamConfig{Name: "L337"}, &scrum.UserState{User: "gfreeman"})
+	work := &fakeTopic{}
+	b.work = work
+
+	b.publishWebhook("L337", webhook.OutOfOffice, scrum.MemberEvent{User: "gfreeman"})
+	if len(work.published) != 1 || len(fs.delivered) != 0 {
+		t.Fatalf("expected the event to be published, got %v %v", work.published, fs.delivered)
+	}
+
+	data, _ := json.Marshal(work.published[0].Payload)
+	b.WorkHandler(&faas.EventContext{Request: &fakeEventRequest{data: data}}, func(ctx *faas.EventContext) (*faas.EventContext, error) { return ctx, nil })
+	expected := `L337 member.outOfOffice {"user":"gfreeman"}`
+	if len(fs.delivered) != 1 || fs.delivered[0] != expected {
+		t.Errorf("expected %q to be delivered, got %v", expected, fs.delivered)
+	}
+}
@@ -1,14 +1,19 @@
 package common
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
 
 const DateFormat = "2006-01-02"
 
+func LoadLocation(tz string) (*time.Location, error) {
+	return time.LoadLocation(strings.TrimSpace(tz))
+}
+
 func NowWithLocation(tz string) (*time.Time, error) {
-	loc, err := time.LoadLocation(strings.TrimSpace(tz))
+	loc, err := LoadLocation(tz)
 	if err != nil {
 		return nil, err
 	}
@@ -18,6 +23,43 @@ func NowWithLocation(tz string) (*time.Time, error) {
 	return &n, nil
 }
 
+// ParseDayStart parses a "15:04" day boundary into an offset from midnight, empty is midnight.
+func ParseDayStart(hhmm string) (time.Duration, error) {
+	if strings.TrimSpace(hhmm) == "" {
+		return 0, nil
+	}
+
+	t, err := time.Parse("15:04", strings.TrimSpace(hhmm))
+	if err != nil {
+		return 0, fmt.Errorf("invalid day start %q, expected HH:MM", hhmm)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// StartOfDay returns when the day containing "at" started, days starting at offset past midnight.
+func StartOfDay(tz string, offset time.Duration, at time.Time) (*time.Time, error) {
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	n := at.In(loc).Add(-offset)
+	s := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, loc).Add(offset)
+
+	return &s, nil
+}
+
+// DayAt returns the date of the day containing "at", days starting at offset past midnight.
+func DayAt(tz string, offset time.Duration, at time.Time) (string, error) {
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return "", err
+	}
+
+	return at.In(loc).Add(-offset).Format(DateFormat), nil
+}
+
 func ToDay(tz string) (string, error) {
 	n, err := NowWithLocation(tz)
 	if err != nil {
@@ -0,0 +1,127 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is how to reach the SMTP server, read from the environment.
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// ConfigFromEnv reads the SMTP_* environment variables, nil if SMTP_HOST isn't set.
+func ConfigFromEnv() (*Config, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, nil
+	}
+
+	cfg := &Config{
+		Host:     host,
+		Port:     25,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q", port)
+		}
+		cfg.Port = p
+	}
+
+	if cfg.From == "" {
+		cfg.From = "scrumpolice@" + host
+	}
+
+	return cfg, nil
+}
+
+// Sender sends multipart emails through an SMTP server.
+type Sender struct {
+	cfg Config
+}
+
+func NewSender(cfg Config) *Sender {
+	return &Sender{cfg: cfg}
+}
+
+// Send sends the email to the recipients with both a plain text and an HTML part.
+func (s *Sender) Send(to []string, subject string, text, html []byte) error {
+	if len(to) == 0 {
+		return nil
+	}
+
+	msg, err := s.message(to, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
+	return smtp.SendMail(addr, auth, s.cfg.From, to, msg)
+}
+
+func (s *Sender) message(to []string, subject string, text, html []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	headers := []string{
+		"From: " + (&mail.Address{Address: s.cfg.From}).String(),
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + w.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write(part.body); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
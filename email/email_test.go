@@ -0,0 +1,90 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a single mail and sends what it got on the channel.
+func fakeSMTPServer(t *testing.T) (string, int, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		data := &strings.Builder{}
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, received
+}
+
+func TestSendMultipartEmail(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+	s := NewSender(Config{Host: host, Port: port, From: "scrumpolice@example.com"})
+
+	err := s.Send([]string{"boss@example.com"}, "L337 scrum report", []byte("plain report"), []byte("<h1>html report</h1>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-received
+	for _, expected := range []string{
+		"To: boss@example.com",
+		"Subject: L337 scrum report",
+		"multipart/alternative",
+		"text/plain; charset=utf-8",
+		"plain report",
+		"text/html; charset=utf-8",
+		"<h1>html report</h1>",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected the mail to contain %q, got:\n%s", expected, msg)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// Slack's Block Kit limits
+	maxBlocksPerMessage = 50
+	maxSectionText      = 3000
+	maxFieldText        = 2000
+	maxFieldsPerSection = 10
+	maxHeaderText       = 150
+)
+
+func init() {
+	Register("blocks", blocksRenderer{})
+}
+
+// BlocksReport is a report as Block Kit blocks, Entries holds the blocks of each member.
+type BlocksReport struct {
+	Header  []slack.Block
+	Entries [][]slack.Block
+	Footer  []slack.Block
+}
+
+// Messages packs the report into as few messages as fit Slack's block limit, a
+// member's entry only spans messages if it is too big on its own.
+func (r *BlocksReport) Messages() [][]slack.Block {
+	groups := append([][]slack.Block{r.Header}, r.Entries...)
+	return PackBlocks(append(groups, r.Footer))
+}
+
+// PackBlocks puts the groups of blocks in messages under Slack's block limit.
+func PackBlocks(groups [][]slack.Block) [][]slack.Block {
+	messages := [][]slack.Block{}
+	current := []slack.Block{}
+	for _, group := range groups {
+		if len(current) > 0 && len(current)+len(group) > maxBlocksPerMessage {
+			messages = append(messages, current)
+			current = []slack.Block{}
+		}
+		for len(group) > maxBlocksPerMessage-len(current) {
+			n := maxBlocksPerMessage - len(current)
+			messages = append(messages, append(current, group[:n]...))
+			current = []slack.Block{}
+			group = group[n:]
+		}
+		current = append(current, group...)
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// Blocks returns the header, entries and who's out of office as Block Kit blocks.
+func Blocks(r *Report) *BlocksReport {
+	blocks := &BlocksReport{
+		Header: []slack.Block{
+			slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, truncateText(r.Header, maxHeaderText), true, false)),
+		},
+	}
+
+	if len(r.Blockers) > 0 {
+		blocks.Header = append(blocks.Header, sectionBlocks("*:construction: Blockers*\n"+BlockersText(r))...)
+	}
+
+	for _, entry := range r.Entries {
+		blocks.Entries = append(blocks.Entries, EntryBlocks(entry))
+	}
+
+	if r.Mood != nil && r.Mood.Responses > 0 {
+		blocks.Footer = append(blocks.Footer, sectionBlocks("*Team mood*\n"+MoodText(r.Mood))...)
+	}
+	if len(r.OutOfOffice) > 0 {
+		blocks.Footer = append(blocks.Footer, ContextBlocks(r.OutOfOfficeText)...)
+	}
+	if r.Stats != nil {
+		blocks.Footer = append(blocks.Footer, ContextBlocks(StatsText(r))...)
+	}
+
+	return blocks
+}
+
+// EntryBlocks returns a member's entry as a divider and sections with the answers as fields.
+func EntryBlocks(entry Entry) []slack.Block {
+	blocks := []slack.Block{slack.NewDividerBlock()}
+	title := "*" + Mention(entry.User, entry.SlackID) + "*"
+
+	if entry.Skipped {
+		return append(blocks, sectionBlocks(title+"\n"+entry.Text)...)
+	}
+
+	fields := []*slack.TextBlockObject{}
+	flush := func() {
+		if len(fields) > 0 || title != "" {
+			var text *slack.TextBlockObject
+			if title != "" {
+				text = slack.NewTextBlockObject(slack.MarkdownType, title, false, false)
+			}
+			blocks = append(blocks, slack.NewSectionBlock(text, fields, nil))
+			title = ""
+			fields = []*slack.TextBlockObject{}
+		}
+	}
+
+	for _, qa := range entry.Answers {
+		text := "*" + qa.Question + "*\n" + qa.Shown()
+		if utf8.RuneCountInString(text) > maxFieldText {
+			// too long for a field, give it sections of its own
+			flush()
+			blocks = append(blocks, sectionBlocks(text)...)
+			continue
+		}
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, text, false, false))
+		if len(fields) == maxFieldsPerSection {
+			flush()
+		}
+	}
+	flush()
+
+	if len(entry.Stuck) > 0 {
+		blocks = append(blocks, ContextBlocks(StuckText(entry))...)
+	}
+
+	return blocks
+}
+
+func sectionBlocks(text string) []slack.Block {
+	blocks := []slack.Block{}
+	for _, chunk := range chunkText(text, maxSectionText) {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+	}
+	return blocks
+}
+
+// ContextBlocks returns the text as context blocks.
+func ContextBlocks(text string) []slack.Block {
+	blocks := []slack.Block{}
+	for _, chunk := range chunkText(text, maxSectionText) {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false)))
+	}
+	return blocks
+}
+
+// chunkText splits text in pieces of at most max runes, on a line break when possible.
+func chunkText(text string, max int) []string {
+	chunks := []string{}
+	for utf8.RuneCountInString(text) > max {
+		runes := []rune(text)
+		cut := strings.LastIndex(string(runes[:max]), "\n")
+		if cut < len(string(runes[:max/2])) {
+			cut = len(string(runes[:max]))
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return append(chunks, text)
+}
+
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// blocksRenderer renders the messages the report is posted as with Block Kit.
+type blocksRenderer struct{}
+
+func (blocksRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (blocksRenderer) Render(r *Report) ([]byte, error) {
+	b := Blocks(r)
+	messages := [][]slack.Block{}
+	messages = append(messages, b.Messages()...)
+	if len(r.NonResponders) > 0 {
+		messages = append(messages, ContextBlocks(r.NonRespondersText))
+	}
+
+	payloads := []slack.Blocks{}
+	for _, m := range messages {
+		payloads = append(payloads, slack.Blocks{BlockSet: m})
+	}
+	return json.MarshalIndent(payloads, "", "  ")
+}
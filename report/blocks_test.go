@@ -0,0 +1,55 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+func TestPackBlocksStaysUnderBlockLimit(t *testing.T) {
+	groups := [][]slack.Block{}
+	for i := 0; i < 30; i++ {
+		groups = append(groups, []slack.Block{slack.NewDividerBlock(), slack.NewDividerBlock(), slack.NewDividerBlock()})
+	}
+
+	messages := PackBlocks(groups)
+	total := 0
+	for _, m := range messages {
+		if len(m) > maxBlocksPerMessage {
+			t.Errorf("message has %d blocks", len(m))
+		}
+		total += len(m)
+	}
+	if total != 90 || len(messages) != 2 {
+		t.Errorf("expected 90 blocks in 2 messages, got %d in %d", total, len(messages))
+	}
+}
+
+func TestChunkTextStaysUnderLimit(t *testing.T) {
+	text := strings.Repeat("héllo wörld\n", 600)
+
+	chunks := chunkText(text, maxSectionText)
+	if len(chunks) < 3 {
+		t.Errorf("expected the text to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if utf8.RuneCountInString(c) > maxSectionText {
+			t.Errorf("chunk has %d runes", utf8.RuneCountInString(c))
+		}
+	}
+}
+
+func TestEntryBlocksSplitsLongAnswers(t *testing.T) {
+	entry := Entry{User: "gfreeman", Answers: []Answer{
+		{Question: "Yesterday?", Answer: strings.Repeat("a", 5000)},
+		{Question: "Today?", Answer: "b"},
+	}}
+
+	blocks := EntryBlocks(entry)
+	// divider, the member, two sections for the long answer and one with the short answer as a field
+	if len(blocks) != 5 {
+		t.Errorf("expected 5 blocks, got %d", len(blocks))
+	}
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+func init() {
+	Register("csv", csvRenderer{})
+}
+
+// csvRenderer renders the report with a row per answer, for spreadsheets. Open blockers,
+// and members that skipped, are out of office or didn't answer get a row with their status.
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (csvRenderer) Render(r *Report) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	rows := [][]string{{"team", "date", "user", "status", "question", "answer"}}
+	for _, b := range r.Blockers {
+		rows = append(rows, []string{r.Team, r.Date, b.User, "blocked", "", b.Text})
+	}
+	for _, entry := range r.Entries {
+		if entry.Skipped {
+			rows = append(rows, []string{r.Team, r.Date, entry.User, "skipped", "", ""})
+			continue
+		}
+		// the raw answer, a scale's number rather than its emoji, is what a spreadsheet wants
+		for _, qa := range entry.Answers {
+			rows = append(rows, []string{r.Team, r.Date, entry.User, "answered", qa.Question, qa.Answer})
+		}
+	}
+	for _, user := range r.OutOfOffice {
+		rows = append(rows, []string{r.Team, r.Date, user, "out of office", "", ""})
+	}
+	for _, user := range r.NonResponders {
+		rows = append(rows, []string{r.Team, r.Date, user, "missing", "", ""})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
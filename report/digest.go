@@ -0,0 +1,187 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/slack-go/slack"
+)
+
+// Digest rolls a team's daily reports up, e.g. over a week.
+type Digest struct {
+	Team          string            `json:"team"`
+	From          string            `json:"from"`
+	To            string            `json:"to"`
+	Days          int               `json:"days"`
+	Questions     []QuestionDigest  `json:"questions"`
+	Participation []Participation   `json:"participation"`
+	SlackIDs      map[string]string `json:"slackIDs"`
+}
+
+// QuestionDigest is what each member answered to a question over the days.
+type QuestionDigest struct {
+	Question string          `json:"question"`
+	Members  []MemberAnswers `json:"members"`
+}
+
+// MemberAnswers is a member's answers to a question by date.
+type MemberAnswers struct {
+	User    string        `json:"user"`
+	Answers []DatedAnswer `json:"answers"`
+}
+
+type DatedAnswer struct {
+	Date   string `json:"date"`
+	Answer string `json:"answer"`
+}
+
+// Participation counts the days a member handed in their scrum, skipped, was out of office
+// or didn't answer.
+type Participation struct {
+	User        string `json:"user"`
+	Reported    int    `json:"reported"`
+	Skipped     int    `json:"skipped"`
+	OutOfOffice int    `json:"outOfOffice"`
+	Missed      int    `json:"missed"`
+}
+
+// NewDigest aggregates the reports, which are expected in date order.
+func NewDigest(team string, reports []*Report) *Digest {
+	d := &Digest{
+		Team:          team,
+		Days:          len(reports),
+		Questions:     []QuestionDigest{},
+		Participation: []Participation{},
+		SlackIDs:      map[string]string{},
+	}
+	if len(reports) == 0 {
+		return d
+	}
+	d.From = reports[0].Date
+	d.To = reports[len(reports)-1].Date
+
+	questions := map[string]int{}
+	members := map[string]map[string]int{}
+	participation := map[string]int{}
+	participant := func(user string) *Participation {
+		i, ok := participation[user]
+		if !ok {
+			i = len(d.Participation)
+			participation[user] = i
+			d.Participation = append(d.Participation, Participation{User: user})
+		}
+		return &d.Participation[i]
+	}
+
+	for _, r := range reports {
+		for user, id := range r.SlackIDs {
+			d.SlackIDs[user] = id
+		}
+		for _, entry := range r.Entries {
+			if entry.Skipped {
+				participant(entry.User).Skipped++
+				continue
+			}
+			participant(entry.User).Reported++
+
+			for _, qa := range entry.Answers {
+				if strings.TrimSpace(qa.Answer) == "" {
+					continue
+				}
+				qi, ok := questions[qa.Question]
+				if !ok {
+					qi = len(d.Questions)
+					questions[qa.Question] = qi
+					members[qa.Question] = map[string]int{}
+					d.Questions = append(d.Questions, QuestionDigest{Question: qa.Question})
+				}
+				q := &d.Questions[qi]
+				mi, ok := members[qa.Question][entry.User]
+				if !ok {
+					mi = len(q.Members)
+					members[qa.Question][entry.User] = mi
+					q.Members = append(q.Members, MemberAnswers{User: entry.User})
+				}
+				q.Members[mi].Answers = append(q.Members[mi].Answers, DatedAnswer{Date: r.Date, Answer: qa.Answer})
+			}
+		}
+		for _, user := range r.OutOfOffice {
+			participant(user).OutOfOffice++
+		}
+		for _, user := range r.NonResponders {
+			participant(user).Missed++
+		}
+	}
+
+	return d
+}
+
+// Header is the first line of the digest message.
+func (d *Digest) Header() string {
+	if d.Days == 0 {
+		return fmt.Sprintf(":calendar: No scrum reports for %s this week.", d.Team)
+	}
+	return fmt.Sprintf(":calendar: Here's the weekly scrum digest for %s, %s to %s!", d.Team, dayLabel(d.From), dayLabel(d.To))
+}
+
+// ParticipationText is a line per member with how many days they handed in their scrum.
+func (d *Digest) ParticipationText() string {
+	lines := []string{}
+	for _, p := range d.Participation {
+		line := fmt.Sprintf("%s: *%d/%d* days", Mention(p.User, d.SlackIDs[p.User]), p.Reported, d.Days)
+		if p.Skipped > 0 {
+			line += fmt.Sprintf(", %d skipped", p.Skipped)
+		}
+		if p.OutOfOffice > 0 {
+			line += fmt.Sprintf(", %d out of office :palm_tree:", p.OutOfOffice)
+		}
+		if p.Missed > 0 {
+			line += fmt.Sprintf(", %d missing", p.Missed)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DigestAttachments returns an attachment per question with each member's answers by day,
+// then the participation.
+func DigestAttachments(d *Digest) []slack.Attachment {
+	attachments := []slack.Attachment{}
+	for _, q := range d.Questions {
+		lines := []string{}
+		for _, m := range q.Members {
+			lines = append(lines, "*"+Mention(m.User, d.SlackIDs[m.User])+"*")
+			for _, a := range m.Answers {
+				lines = append(lines, fmt.Sprintf("_%s_ %s", dayLabel(a.Date), a.Answer))
+			}
+		}
+		attachments = append(attachments, slack.Attachment{
+			Color:      colorful.FastHappyColor().Hex(),
+			MarkdownIn: []string{"text", "pretext"},
+			Pretext:    q.Question,
+			Text:       strings.Join(lines, "\n"),
+		})
+	}
+
+	if len(d.Participation) > 0 {
+		attachments = append(attachments, slack.Attachment{
+			Color:      colorful.FastHappyColor().Hex(),
+			MarkdownIn: []string{"text", "pretext"},
+			Pretext:    "Participation",
+			Text:       d.ParticipationText(),
+		})
+	}
+
+	return attachments
+}
+
+// dayLabel turns a 2006-01-02 date in "Mon Jan 2".
+func dayLabel(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("Mon Jan 2")
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDigestGroupsAnswersByQuestionAndMember(t *testing.T) {
+	reports := []*Report{
+		{
+			Date: "2022-03-21",
+			Entries: []Entry{
+				{User: "gfreeman", Answers: []Answer{{Question: "Today?", Answer: "crowbar"}, {Question: "Blocked?", Answer: ""}}},
+				{User: "wbreen", Skipped: true},
+			},
+			OutOfOffice: []string{"evance"},
+		},
+		{
+			Date: "2022-03-22",
+			Entries: []Entry{
+				{User: "gfreeman", Answers: []Answer{{Question: "Today?", Answer: "headcrabs"}, {Question: "Blocked?", Answer: "the combine"}}},
+			},
+			OutOfOffice:   []string{"evance"},
+			NonResponders: []string{"wbreen"},
+		},
+	}
+
+	d := NewDigest("L337", reports)
+	if d.From != "2022-03-21" || d.To != "2022-03-22" || d.Days != 2 {
+		t.Errorf("unexpected range %s to %s over %d days", d.From, d.To, d.Days)
+	}
+
+	if len(d.Questions) != 2 || d.Questions[0].Question != "Today?" || d.Questions[1].Question != "Blocked?" {
+		t.Fatalf("unexpected questions %+v", d.Questions)
+	}
+	today := d.Questions[0].Members
+	if len(today) != 1 || len(today[0].Answers) != 2 || today[0].Answers[1].Answer != "headcrabs" {
+		t.Errorf("unexpected answers %+v", today)
+	}
+	if len(d.Questions[1].Members[0].Answers) != 1 {
+		t.Errorf("expected empty answers to be left out, got %+v", d.Questions[1].Members)
+	}
+
+	expected := "@gfreeman: *2/2* days\n@wbreen: *0/2* days, 1 skipped, 1 missing\n@evance: *0/2* days, 2 out of office :palm_tree:"
+	if text := d.ParticipationText(); text != expected {
+		t.Errorf("expected participation\n%s\ngot\n%s", expected, text)
+	}
+
+	if !strings.Contains(d.Header(), "Mon Mar 21 to Tue Mar 22") {
+		t.Errorf("unexpected header %s", d.Header())
+	}
+}
@@ -0,0 +1,50 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+)
+
+func init() {
+	Register("html", htmlRenderer{})
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"statsLine": func(s *Stats) string { return StatsLine(s, plainName) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Team}} scrum report for {{.Date}}</title></head>
+<body>
+<h1>{{.Team}} scrum report for {{.Date}}</h1>
+{{if .Blockers}}<h2>Blockers</h2>
+<ul>
+{{range .Blockers}}<li><strong>{{.User}}</strong> (since {{.Since}}): {{.Text}}</li>
+{{end}}</ul>
+{{end}}{{range .Entries}}<h2>{{.User}}</h2>
+{{if .Skipped}}<p>{{.Text}}</p>
+{{else}}<dl>
+{{range .Answers}}<dt>{{.Question}}</dt><dd>{{.Text}}</dd>
+{{end}}</dl>
+{{if .Stuck}}<p><em>Possibly stuck: {{range $i, $s := .Stuck}}{{if $i}}, {{end}}{{$s}}{{end}}</em></p>
+{{end}}{{end}}{{end}}{{if .Mood}}{{if .Mood.Responses}}<p><strong>Team mood:</strong> {{printf "%.1f" .Mood.Average}}/{{.Mood.Max}} from {{.Mood.Responses}} responses</p>
+{{end}}{{end}}{{if .OutOfOffice}}<p><em>Out of office: {{range $i, $u := .OutOfOffice}}{{if $i}}, {{end}}{{$u}}{{end}}</em></p>
+{{end}}{{if .NonResponders}}<p><em>No report from: {{range $i, $u := .NonResponders}}{{if $i}}, {{end}}{{$u}}{{end}}</em></p>
+{{end}}{{if .Stats}}<p><small>{{statsLine .Stats}}</small></p>
+{{end}}</body>
+</html>
+`))
+
+// htmlRenderer renders the report as an HTML page.
+type htmlRenderer struct{}
+
+func (htmlRenderer) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+func (htmlRenderer) Render(r *Report) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := htmlTemplate.Execute(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,18 @@
+package report
+
+import "encoding/json"
+
+func init() {
+	Register("json", jsonRenderer{})
+}
+
+// jsonRenderer renders the report data as is.
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (jsonRenderer) Render(r *Report) ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	Register("markdown", markdownRenderer{})
+}
+
+// markdownRenderer renders the report as a Markdown page, e.g. for a wiki.
+type markdownRenderer struct{}
+
+func (markdownRenderer) ContentType() string {
+	return "text/markdown; charset=utf-8"
+}
+
+func (markdownRenderer) Render(r *Report) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s scrum report for %s\n\n", r.Team, r.Date)
+
+	if len(r.Blockers) > 0 {
+		fmt.Fprintf(buf, "## Blockers\n\n")
+		for _, b := range r.Blockers {
+			fmt.Fprintf(buf, "- **%s** (since %s): %s\n", b.User, b.Since, b.Text)
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+
+	for _, entry := range r.Entries {
+		fmt.Fprintf(buf, "## %s\n\n", entry.User)
+		if entry.Skipped {
+			fmt.Fprintf(buf, "%s\n\n", entry.Text)
+			continue
+		}
+		for _, qa := range entry.Answers {
+			fmt.Fprintf(buf, "**%s**\n\n%s\n\n", qa.Question, qa.Text())
+		}
+		if len(entry.Stuck) > 0 {
+			fmt.Fprintf(buf, "_Possibly stuck: %s_\n\n", strings.Join(entry.Stuck, ", "))
+		}
+	}
+
+	if r.Mood != nil && r.Mood.Responses > 0 {
+		fmt.Fprintf(buf, "**Team mood:** %.1f/%d from %d responses", r.Mood.Average, r.Mood.Max, r.Mood.Responses)
+		if len(r.Mood.Trend) > 1 {
+			fmt.Fprintf(buf, ", last %d days %s", len(r.Mood.Trend), Sparkline(r.Mood.Trend, r.Mood.Min, r.Mood.Max))
+		}
+		fmt.Fprintf(buf, "\n\n")
+	}
+
+	if len(r.OutOfOffice) > 0 {
+		fmt.Fprintf(buf, "_Out of office: %s_\n\n", strings.Join(r.OutOfOffice, ", "))
+	}
+	if len(r.NonResponders) > 0 {
+		fmt.Fprintf(buf, "_No report from: %s_\n\n", strings.Join(r.NonResponders, ", "))
+	}
+	if r.Stats != nil {
+		fmt.Fprintf(buf, "_%s_\n", StatsLine(r.Stats, plainName))
+	}
+
+	return buf.Bytes(), nil
+}
+
+func plainName(user string) string {
+	return user
+}
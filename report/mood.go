@@ -0,0 +1,65 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mood is the team's mood for the day, only as an aggregate as members can keep their
+// own score private.
+type Mood struct {
+	Responses int     `json:"responses"`
+	Average   float64 `json:"average"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	// Distribution counts the responses by score, from Min to Max
+	Distribution []int       `json:"distribution"`
+	Trend        []MoodPoint `json:"trend"`
+}
+
+// MoodPoint is the team's average mood on a day.
+type MoodPoint struct {
+	Date      string  `json:"date"`
+	Average   float64 `json:"average"`
+	Responses int     `json:"responses"`
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the averages of the points between min and max.
+func Sparkline(points []MoodPoint, min, max int) string {
+	if max <= min {
+		return ""
+	}
+
+	line := []rune{}
+	for _, p := range points {
+		i := int((p.Average - float64(min)) / float64(max-min) * float64(len(sparks)-1))
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sparks) {
+			i = len(sparks) - 1
+		}
+		line = append(line, sparks[i])
+	}
+	return string(line)
+}
+
+// MoodText is the mood as a few lines of Slack markdown.
+func MoodText(m *Mood) string {
+	lines := []string{fmt.Sprintf("*%.1f/%d* average from %d responses", m.Average, m.Max, m.Responses)}
+
+	counts := []string{}
+	for i, n := range m.Distribution {
+		counts = append(counts, fmt.Sprintf("%d: %d", m.Min+i, n))
+	}
+	if len(counts) > 0 {
+		lines = append(lines, strings.Join(counts, "  "))
+	}
+
+	if len(m.Trend) > 1 {
+		lines = append(lines, fmt.Sprintf("Last %d days: %s", len(m.Trend), Sparkline(m.Trend, m.Min, m.Max)))
+	}
+	return strings.Join(lines, "\n")
+}
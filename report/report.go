@@ -0,0 +1,156 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is a team's scrum report, independent of where it is going to be posted.
+// The *Text fields hold the wording from the team's templates, SlackIDs maps the
+// members to their Slack user IDs when they are known.
+type Report struct {
+	Team              string            `json:"team"`
+	Date              string            `json:"date"`
+	Header            string            `json:"header"`
+	EntryTitle        string            `json:"entryTitle"`
+	Summary           string            `json:"summary"`
+	Blockers          []Blocker         `json:"blockers"`
+	Mood              *Mood             `json:"mood"`
+	Members           int               `json:"members"`
+	Entries           []Entry           `json:"entries"`
+	OutOfOffice       []string          `json:"outOfOffice"`
+	OutOfOfficeText   string            `json:"outOfOfficeText"`
+	NonResponders     []string          `json:"nonResponders"`
+	NonRespondersText string            `json:"nonRespondersText"`
+	SlackIDs          map[string]string `json:"slackIDs"`
+	Stats             *Stats            `json:"stats"`
+}
+
+// Mention returns how the member is mentioned in Slack.
+func (r *Report) Mention(user string) string {
+	return Mention(user, r.SlackIDs[user])
+}
+
+// Mention returns a <@U123> mention when the user's Slack ID is known, a plain @name
+// otherwise.
+func Mention(user, slackID string) string {
+	if slackID != "" {
+		return "<@" + slackID + ">"
+	}
+	return "@" + user
+}
+
+// Entry is a member's scrum, Text is the entry (or skipped) wording. Stuck holds the
+// items of their plan that have been planned for a few reports in a row. Prompted and
+// Submitted are RFC 3339 times, Prompted is empty if they weren't prompted that day.
+type Entry struct {
+	User      string   `json:"user"`
+	SlackID   string   `json:"slackID"`
+	Skipped   bool     `json:"skipped"`
+	Text      string   `json:"text"`
+	Answers   []Answer `json:"answers"`
+	Stuck     []string `json:"stuck"`
+	Prompted  string   `json:"prompted"`
+	Submitted string   `json:"submitted"`
+}
+
+// TimeToSubmit returns how long after the prompt the entry was handed in, false if
+// it isn't known.
+func (e Entry) TimeToSubmit() (time.Duration, bool) {
+	prompted, err := time.Parse(time.RFC3339, e.Prompted)
+	if err != nil {
+		return 0, false
+	}
+	submitted, err := time.Parse(time.RFC3339, e.Submitted)
+	if err != nil || submitted.Before(prompted) {
+		return 0, false
+	}
+	return submitted.Sub(prompted), true
+}
+
+// StuckText is the line flagging the entry's stuck items, empty if there are none.
+func StuckText(entry Entry) string {
+	if len(entry.Stuck) == 0 {
+		return ""
+	}
+	return ":warning: Possibly stuck: " + strings.Join(entry.Stuck, ", ")
+}
+
+// Answer is a question and what the member answered, Display is the answer as shown
+// in Slack when it differs (e.g. a scale as emoji) and Plain the same without the emoji.
+type Answer struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Display  string `json:"display"`
+	Plain    string `json:"plain,omitempty"`
+}
+
+// Shown returns the answer as shown in Slack.
+func (a Answer) Shown() string {
+	if a.Display != "" {
+		return a.Display
+	}
+	return a.Answer
+}
+
+// Text returns the answer as shown in the markdown and HTML reports, Slack's emoji
+// shortcodes mean nothing there.
+func (a Answer) Text() string {
+	if a.Plain != "" {
+		return a.Plain
+	}
+	return a.Answer
+}
+
+// Blocker is something a member reported is blocking them, it stays on the reports
+// until it is resolved.
+type Blocker struct {
+	ID       string   `json:"id"`
+	User     string   `json:"user"`
+	Text     string   `json:"text"`
+	Since    string   `json:"since"`
+	Mentions []string `json:"mentions"`
+}
+
+// BlockersText is a line per open blocker.
+func BlockersText(r *Report) string {
+	lines := []string{}
+	for _, b := range r.Blockers {
+		lines = append(lines, fmt.Sprintf("`%s` %s: %s _(since %s)_", b.ID, r.Mention(b.User), b.Text, dayLabel(b.Since)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Renderer turns a report into one output format.
+type Renderer interface {
+	ContentType() string
+	Render(r *Report) ([]byte, error)
+}
+
+var renderers = map[string]Renderer{}
+
+// Register makes a renderer available by name, renderers register themselves in init.
+func Register(name string, r Renderer) {
+	renderers[name] = r
+}
+
+// Get returns the renderer registered with the name.
+func Get(name string) (Renderer, error) {
+	r, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q, expected one of %v", name, Names())
+	}
+	return r, nil
+}
+
+// Names returns the names of the registered renderers.
+func Names() []string {
+	names := []string{}
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
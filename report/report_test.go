@@ -0,0 +1,85 @@
+package report
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testReport = &Report{
+	Team:    "L337",
+	Date:    "2022-03-21",
+	Members: 3,
+	Entries: []Entry{
+		{User: "gfreeman", Answers: []Answer{{Question: "Yesterday?", Answer: "crowbar, headcrabs"}}},
+	},
+	OutOfOffice:   []string{"evance"},
+	NonResponders: []string{"wbreen"},
+}
+
+func TestCSVHasARowPerAnswerAndStatus(t *testing.T) {
+	r, _ := Get("csv")
+	b, err := r.Render(testReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "team,date,user,status,question,answer\n" +
+		"L337,2022-03-21,gfreeman,answered,Yesterday?,\"crowbar, headcrabs\"\n" +
+		"L337,2022-03-21,evance,out of office,,\n" +
+		"L337,2022-03-21,wbreen,missing,,\n"
+	if string(b) != expected {
+		t.Errorf("unexpected csv %q", string(b))
+	}
+}
+
+func TestHTMLEscapesAnswers(t *testing.T) {
+	r, _ := Get("html")
+	b, err := r.Render(&Report{Entries: []Entry{{User: "gfreeman", Answers: []Answer{{Question: "Q", Answer: "<script>"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "<script>") {
+		t.Error("answer wasn't escaped")
+	}
+}
+
+func TestGetUnknownFormat(t *testing.T) {
+	if _, err := Get("pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestMoodText(t *testing.T) {
+	m := &Mood{
+		Responses:    3,
+		Average:      11.0 / 3,
+		Min:          1,
+		Max:          5,
+		Distribution: []int{0, 0, 1, 2, 0},
+		Trend:        []MoodPoint{{Average: 1}, {Average: 3}, {Average: 5}},
+	}
+
+	expected := "*3.7/5* average from 3 responses\n1: 0  2: 0  3: 1  4: 2  5: 0\nLast 3 days: ▁▄█"
+	if text := MoodText(m); text != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestRenderersLeaveOutTheShortcodes(t *testing.T) {
+	report := &Report{Entries: []Entry{{User: "gfreeman", Answers: []Answer{
+		{Question: "Blocked?", Answer: "no", Display: ":x: No", Plain: "No"},
+		{Question: "Mood?", Answer: "4", Display: ":slightly_smiling_face: 4/5", Plain: "4/5"},
+	}}}}
+	shortcode := regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+	for _, format := range []string{"markdown", "html"} {
+		r, _ := Get(format)
+		b, err := r.Render(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shortcode.Match(b) || !strings.Contains(string(b), "No") || !strings.Contains(string(b), "4/5") {
+			t.Errorf("%s: expected the answers without shortcodes, got %s", format, b)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package report
+
+import (
+	"encoding/json"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	Register("slack", slackRenderer{})
+}
+
+// Attachments returns the entries and who's out of office as legacy Slack attachments.
+func Attachments(r *Report) []slack.Attachment {
+	attachments := []slack.Attachment{}
+	if len(r.Blockers) > 0 {
+		attachments = append(attachments, BlockersAttachment(r))
+	}
+
+	for _, entry := range r.Entries {
+		attachments = append(attachments, EntryAttachment(entry))
+	}
+
+	if r.Mood != nil && r.Mood.Responses > 0 {
+		attachments = append(attachments, slack.Attachment{
+			Color:      colorful.FastHappyColor().Hex(),
+			MarkdownIn: []string{"text", "pretext"},
+			Pretext:    "Team mood",
+			Text:       MoodText(r.Mood),
+		})
+	}
+
+	if len(r.OutOfOffice) > 0 {
+		attachment := slack.Attachment{
+			Color:      colorful.FastHappyColor().Hex(),
+			MarkdownIn: []string{"text", "pretext"},
+			Pretext:    "Currently out of office",
+			Text:       r.OutOfOfficeText,
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	if r.Stats != nil {
+		attachments = append(attachments, slack.Attachment{Footer: StatsText(r)})
+	}
+
+	return attachments
+}
+
+// BlockersAttachment returns the open blockers as a Slack attachment.
+func BlockersAttachment(r *Report) slack.Attachment {
+	return slack.Attachment{
+		Color:      "#d72b3f",
+		MarkdownIn: []string{"text", "pretext"},
+		Pretext:    ":construction: Blockers",
+		Text:       BlockersText(r),
+	}
+}
+
+// EntryAttachment returns a member's entry as a Slack attachment.
+func EntryAttachment(entry Entry) slack.Attachment {
+	return slack.Attachment{
+		Color:      colorful.FastHappyColor().Hex(),
+		MarkdownIn: []string{"text", "pretext"},
+		Pretext:    Mention(entry.User, entry.SlackID),
+		Text:       entry.Text,
+		Footer:     StuckText(entry),
+	}
+}
+
+// slackRenderer renders the messages the report is posted as with attachments.
+type slackRenderer struct{}
+
+func (slackRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (slackRenderer) Render(r *Report) ([]byte, error) {
+	messages := []slack.Msg{{Text: r.Header, Attachments: Attachments(r)}}
+	if len(r.NonResponders) > 0 {
+		messages = append(messages, slack.Msg{Text: r.NonRespondersText})
+	}
+	return json.MarshalIndent(messages, "", "  ")
+}
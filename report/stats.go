@@ -0,0 +1,192 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats are a team's participation figures over a range of reports, with each member's.
+type Stats struct {
+	Team    string        `json:"team"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Days    int           `json:"days"`
+	Totals  Counts        `json:"totals"`
+	Members []MemberStats `json:"members"`
+}
+
+// Counts are how many entries were answered, skipped, out of office or missing. Rate is the
+// share of the expected entries (all but out of office) that were handed in and
+// MedianSubmitSeconds the median time from the prompt to the entry, 0 if unknown.
+type Counts struct {
+	Answered            int     `json:"answered"`
+	Skipped             int     `json:"skipped"`
+	OutOfOffice         int     `json:"outOfOffice"`
+	Missing             int     `json:"missing"`
+	Rate                float64 `json:"rate"`
+	MedianSubmitSeconds int64   `json:"medianSubmitSeconds"`
+}
+
+// MemberStats are a member's figures, Streak is how many reports in a row they handed in
+// up to the last one, being out of office doesn't break it.
+type MemberStats struct {
+	User   string `json:"user"`
+	Counts Counts `json:"counts"`
+	Streak int    `json:"streak"`
+}
+
+// tally accumulates the counts and the submit times they are computed from.
+type tally struct {
+	counts  Counts
+	submits []time.Duration
+}
+
+func (t *tally) add(entry Entry) {
+	if entry.Skipped {
+		t.counts.Skipped++
+	} else {
+		t.counts.Answered++
+	}
+	if d, ok := entry.TimeToSubmit(); ok {
+		t.submits = append(t.submits, d)
+	}
+}
+
+func (t *tally) done() Counts {
+	c := t.counts
+	if expected := c.Answered + c.Skipped + c.Missing; expected > 0 {
+		c.Rate = float64(c.Answered+c.Skipped) / float64(expected)
+	}
+	if len(t.submits) > 0 {
+		c.MedianSubmitSeconds = int64(median(t.submits) / time.Second)
+	}
+	return c
+}
+
+func median(ds []time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// NewStats computes the figures of the reports, which are expected in date order.
+func NewStats(team string, reports []*Report) *Stats {
+	s := &Stats{Team: team, Days: len(reports), Members: []MemberStats{}}
+	if len(reports) == 0 {
+		return s
+	}
+	s.From = reports[0].Date
+	s.To = reports[len(reports)-1].Date
+
+	total := &tally{}
+	users := []string{}
+	members := map[string]*tally{}
+	member := func(user string) *tally {
+		t, ok := members[user]
+		if !ok {
+			t = &tally{}
+			members[user] = t
+			users = append(users, user)
+		}
+		return t
+	}
+
+	for _, r := range reports {
+		for _, entry := range r.Entries {
+			total.add(entry)
+			member(entry.User).add(entry)
+		}
+		for _, user := range r.OutOfOffice {
+			total.counts.OutOfOffice++
+			member(user).counts.OutOfOffice++
+		}
+		for _, user := range r.NonResponders {
+			total.counts.Missing++
+			member(user).counts.Missing++
+		}
+	}
+
+	streaks := Streaks(reports)
+	s.Totals = total.done()
+	for _, user := range users {
+		s.Members = append(s.Members, MemberStats{User: user, Counts: members[user].done(), Streak: streaks[user]})
+	}
+	return s
+}
+
+// Streaks returns how many reports in a row each member handed in up to the last report,
+// the reports are expected in date order.
+func Streaks(reports []*Report) map[string]int {
+	streaks := map[string]int{}
+	broken := map[string]bool{}
+	for i := len(reports) - 1; i >= 0; i-- {
+		for _, entry := range reports[i].Entries {
+			if !broken[entry.User] {
+				streaks[entry.User]++
+			}
+		}
+		for _, user := range reports[i].NonResponders {
+			broken[user] = true
+		}
+	}
+	return streaks
+}
+
+// Member returns the member's figures, nil if they aren't in the stats.
+func (s *Stats) Member(user string) *MemberStats {
+	for i := range s.Members {
+		if s.Members[i].User == user {
+			return &s.Members[i]
+		}
+	}
+	return nil
+}
+
+// durationLabel turns seconds in e.g. "1h05m" or "25m".
+func durationLabel(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute)/time.Minute))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int((d%time.Hour).Round(time.Minute)/time.Minute))
+}
+
+// StatsLine is the stats as one line, mention is how the members are named.
+func StatsLine(s *Stats, mention func(string) string) string {
+	c := s.Totals
+	parts := []string{fmt.Sprintf("Participation %.0f%% (%d answered, %d skipped, %d out of office, %d missing)",
+		c.Rate*100, c.Answered, c.Skipped, c.OutOfOffice, c.Missing)}
+	if c.MedianSubmitSeconds > 0 {
+		parts = append(parts, fmt.Sprintf("median %s after the prompt", durationLabel(c.MedianSubmitSeconds)))
+	}
+
+	streaks := []MemberStats{}
+	for _, m := range s.Members {
+		if m.Streak > 1 {
+			streaks = append(streaks, m)
+		}
+	}
+	sort.SliceStable(streaks, func(i, j int) bool { return streaks[i].Streak > streaks[j].Streak })
+	if len(streaks) > 0 {
+		names := []string{}
+		for _, m := range streaks {
+			names = append(names, fmt.Sprintf("%s %d", mention(m.User), m.Streak))
+		}
+		parts = append(parts, "streaks: "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// StatsText is the report's stats footer for Slack, empty if it has none.
+func StatsText(r *Report) string {
+	if r.Stats == nil {
+		return ""
+	}
+	return StatsLine(r.Stats, r.Mention)
+}
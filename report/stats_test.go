@@ -0,0 +1,60 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsCountsRateMedianAndStreaks(t *testing.T) {
+	reports := []*Report{
+		{
+			Date: "2022-03-21",
+			Entries: []Entry{
+				{User: "gfreeman", Prompted: "2022-03-21T09:00:00Z", Submitted: "2022-03-21T09:10:00Z"},
+			},
+			NonResponders: []string{"wbreen"},
+		},
+		{
+			Date: "2022-03-22",
+			Entries: []Entry{
+				{User: "gfreeman", Prompted: "2022-03-22T09:00:00Z", Submitted: "2022-03-22T09:30:00Z"},
+				{User: "wbreen", Skipped: true, Prompted: "2022-03-22T09:00:00Z", Submitted: "2022-03-22T10:00:00Z"},
+			},
+			OutOfOffice: []string{"evance"},
+		},
+		{
+			Date: "2022-03-23",
+			Entries: []Entry{
+				{User: "gfreeman", Submitted: "2022-03-23T09:00:00Z"},
+				{User: "wbreen"},
+			},
+			OutOfOffice: []string{"evance"},
+		},
+	}
+
+	s := NewStats("L337", reports)
+	if s.Totals.Answered != 4 || s.Totals.Skipped != 1 || s.Totals.OutOfOffice != 2 || s.Totals.Missing != 1 {
+		t.Errorf("unexpected totals %+v", s.Totals)
+	}
+	if s.Totals.Rate < 0.83 || s.Totals.Rate > 0.84 {
+		t.Errorf("expected a rate of 5/6, got %v", s.Totals.Rate)
+	}
+	if s.Totals.MedianSubmitSeconds != 30*60 {
+		t.Errorf("expected a median of 30 minutes, got %ds", s.Totals.MedianSubmitSeconds)
+	}
+
+	if m := s.Member("gfreeman"); m == nil || m.Streak != 3 || m.Counts.MedianSubmitSeconds != 20*60 {
+		t.Errorf("unexpected gfreeman stats %+v", m)
+	}
+	if m := s.Member("wbreen"); m == nil || m.Streak != 2 {
+		t.Errorf("unexpected wbreen stats %+v", m)
+	}
+	if m := s.Member("evance"); m == nil || m.Streak != 0 || m.Counts.OutOfOffice != 2 {
+		t.Errorf("unexpected evance stats %+v", m)
+	}
+
+	line := StatsLine(s, func(user string) string { return "@" + user })
+	if !strings.Contains(line, "Participation 83%") || !strings.Contains(line, "median 30m") || !strings.Contains(line, "streaks: @gfreeman 3, @wbreen 2") {
+		t.Errorf("unexpected stats line %q", line)
+	}
+}
@@ -0,0 +1,261 @@
+package scrum
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/asalkeld/scrumpolice/webhook"
+	"github.com/nitrictech/go-sdk/api/documents"
+	log "github.com/sirupsen/logrus"
+)
+
+// Blocker is something a member reported is blocking them, it is carried over from
+// report to report until it is resolved.
+type Blocker struct {
+	ID           string   `json:"id"`
+	Team         string   `json:"team"`
+	User         string   `json:"user"`
+	Text         string   `json:"text"`
+	Mentions     []string `json:"mentions"`
+	Reported     string   `json:"reported"`
+	LastReported string   `json:"lastReported"`
+	Resolved     bool     `json:"resolved"`
+	ResolvedBy   string   `json:"resolvedBy"`
+	ResolvedDate string   `json:"resolvedDate"`
+}
+
+var (
+	// noBlockers are the answers that mean there is nothing blocking.
+	noBlockers = map[string]bool{"": true, "no": true, "nope": true, "none": true, "nothing": true, "n/a": true, "na": true, "-": true, "no blockers": true, "not blocked": true, "nobody": true}
+
+	slackMentionRegex = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+	plainMentionRegex = regexp.MustCompile(`(?:^|\s)@([\w.\-]+)`)
+	slackIDRegex      = regexp.MustCompile(`^[UW][A-Z0-9]{6,}$`)
+)
+
+// IsBlockerQuestion tells if the question is the team's blockerQuestion, blockers are only
+// tracked for teams that set one.
+func (tc *TeamConfig) IsBlockerQuestion(question string) bool {
+	return tc.BlockerQuestion != "" && question == tc.BlockerQuestion
+}
+
+// Blockers returns the member's answers to the blocker questions that report something blocking.
+func (tc *TeamConfig) Blockers(us *UserState) map[string]string {
+	blockers := map[string]string{}
+	for _, q := range tc.Questions {
+		if !tc.IsBlockerQuestion(q.Text) {
+			continue
+		}
+		answer := strings.TrimSpace(us.Answers[q.Text])
+		if noBlockers[strings.Trim(strings.ToLower(answer), ".!")] {
+			continue
+		}
+		blockers[q.Text] = answer
+	}
+	return blockers
+}
+
+// Mentions returns the users mentioned in the text, either as Slack user IDs for
+// <@U123> mentions or as names for plain @name ones.
+func Mentions(text string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, regex := range []*regexp.Regexp{slackMentionRegex, plainMentionRegex} {
+		for _, m := range regex.FindAllStringSubmatch(text, -1) {
+			mention := strings.TrimRight(m[1], ".-")
+			if !seen[mention] {
+				seen[mention] = true
+				mentions = append(mentions, mention)
+			}
+		}
+	}
+	return mentions
+}
+
+// dmTarget returns where to send a direct message to the mentioned user.
+func (mod *service) dmTarget(mention string) string {
+	if slackIDRegex.MatchString(mention) {
+		return mention
+	}
+	if id := mod.slackIDFor(mention); id != "" {
+		return id
+	}
+	return "@" + mention
+}
+
+// reportBlockers returns the open blockers as they go on the report.
+func reportBlockers(blockers []*Blocker) []report.Blocker {
+	rb := []report.Blocker{}
+	for _, b := range blockers {
+		rb = append(rb, report.Blocker{ID: b.ID, User: b.User, Text: b.Text, Since: b.Reported, Mentions: b.Mentions})
+	}
+	return rb
+}
+
+// OpenBlockers returns the team's blockers that haven't been resolved.
+func (mod *service) OpenBlockers(team string) ([]*Blocker, error) {
+	results, err := blockerCol.Query().Where(
+		documents.Condition("team").Eq(documents.StringValue(team)),
+		documents.Condition("resolved").Eq(documents.BoolValue(false)),
+	).Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	blockers := []*Blocker{}
+	for _, doc := range results.Documents {
+		b := &Blocker{}
+		if err := decodeWithJsonTags(doc.Content(), b); err != nil {
+			return nil, err
+		}
+		blockers = append(blockers, b)
+	}
+	return blockers, nil
+}
+
+func (mod *service) saveBlocker(b *Blocker) error {
+	blockerMap := map[string]interface{}{}
+	err := decodeWithJsonTags(b, &blockerMap)
+	if err != nil {
+		return err
+	}
+	return blockerCol.Doc(b.Team + "@" + b.ID).Set(blockerMap)
+}
+
+// trackBlockers records the blockers in the member's entry, a blocker they already
+// reported is kept rather than added again. The mentioned users are told about new ones.
+// When the member changes their answer, the blockers they reported earlier that day are
+// closed.
+func (mod *service) trackBlockers(tc *TeamConfig, us *UserState, date string) error {
+	if tc.BlockerQuestion == "" {
+		return nil
+	}
+	reported := tc.Blockers(us)
+
+	open, err := mod.OpenBlockers(tc.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range open {
+		if b.User != us.User || b.LastReported != date || stillReported(b, reported) {
+			continue
+		}
+		b.Resolved = true
+		b.ResolvedBy = us.User
+		b.ResolvedDate = date
+		if err := mod.saveBlocker(b); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"team":    tc.Name,
+			"user":    us.User,
+			"blocker": b.ID,
+		}).Info("Blocker replaced by an edited answer.")
+	}
+
+	for question, text := range reported {
+		var blocker *Blocker
+		for _, b := range open {
+			if b.User == us.User && strings.EqualFold(b.Text, text) {
+				blocker = b
+				break
+			}
+		}
+
+		if blocker != nil {
+			blocker.LastReported = date
+			if err := mod.saveBlocker(blocker); err != nil {
+				return err
+			}
+			continue
+		}
+
+		blocker = &Blocker{
+			ID:           mod.newBlockerID(tc.Name),
+			Team:         tc.Name,
+			User:         us.User,
+			Text:         text,
+			Mentions:     Mentions(text),
+			Reported:     date,
+			LastReported: date,
+		}
+		if err := mod.saveBlocker(blocker); err != nil {
+			return err
+		}
+
+		for _, mention := range blocker.Mentions {
+			mod.postMessageToSlack(mod.dmTarget(mention), fmt.Sprintf(":construction: %s from team %s is blocked by you: %s", us.mention(), tc.Name, text), SlackParams)
+		}
+		mod.notify(tc, webhook.BlockerReported, BlockerEvent{ID: blocker.ID, Date: date, User: us.User, Question: question, Answer: text, Mentions: blocker.Mentions})
+
+		log.WithFields(log.Fields{
+			"team":    tc.Name,
+			"user":    us.User,
+			"blocker": blocker.ID,
+		}).Info("Blocker reported.")
+	}
+	return nil
+}
+
+// ResolveBlockers resolves the team's blocker with the ID, or all of the member's own
+// open blockers if the ID is empty, and returns the ones that were resolved.
+func (mod *service) ResolveBlockers(tc *TeamConfig, username string, id string) ([]*Blocker, error) {
+	open, err := mod.OpenBlockers(tc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	today, err := tc.DayIn(tc.Timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := []*Blocker{}
+	for _, b := range open {
+		if (id != "" && b.ID != id) || (id == "" && b.User != username) {
+			continue
+		}
+		b.Resolved = true
+		b.ResolvedBy = username
+		b.ResolvedDate = today
+		if err := mod.saveBlocker(b); err != nil {
+			return resolved, err
+		}
+		resolved = append(resolved, b)
+	}
+	return resolved, nil
+}
+
+// stillReported tells if the blocker is one of the reported answers.
+func stillReported(b *Blocker, reported map[string]string) bool {
+	for _, text := range reported {
+		if strings.EqualFold(b.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// newBlockerID returns a short ID to `resolve` the blocker with that no other blocker of the
+// team has, a longer one if the short ones keep clashing.
+func (mod *service) newBlockerID(team string) string {
+	for i := 0; i < 10; i++ {
+		id := randomHex(3)
+		if _, err := blockerCol.Doc(team + "@" + id).Get(); err != nil {
+			return id
+		}
+	}
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,169 @@
+package scrum
+
+import (
+	"strings"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// stuckAfter is how many reports in a row an item has to be planned to be flagged as stuck
+	stuckAfter = 3
+	// carryOverDays is how far back the previous plans are looked for, to get over weekends and holidays
+	carryOverDays = 14
+)
+
+// planQuestions returns the questions other questions follow up on, e.g. "What will you do today?"
+// for "What did you do yesterday?".
+func (tc *TeamConfig) planQuestions() []string {
+	plans := []string{}
+	for _, q := range tc.Questions {
+		if q.PlannedIn != "" {
+			plans = append(plans, q.PlannedIn)
+		}
+	}
+	return plans
+}
+
+// planItems splits a plan in its items, a line or bullet each.
+func planItems(plan string) []string {
+	items := []string{}
+	for _, line := range strings.Split(plan, "\n") {
+		item := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func normaliseItem(item string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.TrimRight(item, ".!"))), " ")
+}
+
+// stuckItems returns the items of the last plan that are in all the plans, the plans are oldest first.
+func stuckItems(plans []string) []string {
+	if len(plans) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, plan := range plans[:len(plans)-1] {
+		seen := map[string]bool{}
+		for _, item := range planItems(plan) {
+			n := normaliseItem(item)
+			if !seen[n] {
+				seen[n] = true
+				counts[n]++
+			}
+		}
+	}
+
+	stuck := []string{}
+	for _, item := range planItems(plans[len(plans)-1]) {
+		if counts[normaliseItem(item)] == len(plans)-1 {
+			stuck = append(stuck, item)
+		}
+	}
+	return stuck
+}
+
+// answerIn returns the answer to the question in the entry.
+func answerIn(entry report.Entry, question string) (string, bool) {
+	for _, a := range entry.Answers {
+		if a.Question == question {
+			return a.Answer, true
+		}
+	}
+	return "", false
+}
+
+// previousReports returns the team's reports before the date, oldest first.
+func (mod *service) previousReports(tc *TeamConfig, date string) ([]*report.Report, error) {
+	day, err := time.Parse(common.DateFormat, date)
+	if err != nil {
+		return nil, err
+	}
+	from := day.AddDate(0, 0, -carryOverDays).Format(common.DateFormat)
+	to := day.AddDate(0, 0, -1).Format(common.DateFormat)
+	return mod.reportsBetween(tc, from, to)
+}
+
+// PreviousAnswer returns the date and the member's latest answer to the question before
+// today, empty if there is none.
+func (mod *service) PreviousAnswer(tc *TeamConfig, us *UserState, question string) (string, string) {
+	today, err := tc.DayFor(us, time.Now())
+	if err != nil {
+		return "", ""
+	}
+
+	reports, err := mod.previousReports(tc, today)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the report history")
+		return "", ""
+	}
+
+	for i := len(reports) - 1; i >= 0; i-- {
+		for _, entry := range reports[i].Entries {
+			if entry.User != us.User {
+				continue
+			}
+			if answer, ok := answerIn(entry, question); ok && strings.TrimSpace(answer) != "" {
+				return reports[i].Date, answer
+			}
+		}
+	}
+	return "", ""
+}
+
+// flagStuck flags the items members planned in their last reports in a row.
+func (mod *service) flagStuck(tc *TeamConfig, r *report.Report) {
+	plans := tc.planQuestions()
+	if len(plans) == 0 {
+		return
+	}
+
+	reports, err := mod.previousReports(tc, r.Date)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the report history")
+		return
+	}
+
+	// each member's entries, newest first
+	previous := map[string][]report.Entry{}
+	for i := len(reports) - 1; i >= 0; i-- {
+		for _, entry := range reports[i].Entries {
+			previous[entry.User] = append(previous[entry.User], entry)
+		}
+	}
+
+	for i := range r.Entries {
+		entry := &r.Entries[i]
+		if entry.Skipped {
+			continue
+		}
+
+		for _, question := range plans {
+			today, ok := answerIn(*entry, question)
+			if !ok {
+				continue
+			}
+
+			history := []string{today}
+			for _, prev := range previous[entry.User] {
+				if len(history) == stuckAfter {
+					break
+				}
+				if answer, ok := answerIn(prev, question); ok {
+					history = append([]string{answer}, history...)
+				}
+			}
+			if len(history) == stuckAfter {
+				entry.Stuck = append(entry.Stuck, stuckItems(history)...)
+			}
+		}
+	}
+}
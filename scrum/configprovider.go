@@ -83,6 +83,7 @@ func decodeWithJsonTags(input interface{}, output interface{}) error {
 		ErrorUnused: true,
 		Result:      output,
 		TagName:     "json",
+		DecodeHook:  questionDecodeHook,
 	}
 
 	decoder, err := mapstructure.NewDecoder(config)
@@ -104,6 +105,10 @@ func (sc *store) PostHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faa
 		return common.HttpResponse(ctx, "error decoding json body", 400)
 	}
 
+	if err := store.Validate(); err != nil {
+		return common.HttpResponse(ctx, "invalid team configuration: "+err.Error(), 400)
+	}
+
 	// Convert the document to a map[string]interface{}
 	// for storage, future iterations of the go-sdk may include direct interface{} storage as well
 	storeMap := make(map[string]interface{})
@@ -134,7 +139,7 @@ func (sc *store) ListHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faa
 
 	docs := make([]map[string]interface{}, 0)
 	for _, doc := range results.Documents {
-		docs = append(docs, doc.Content())
+		docs = append(docs, redactSecrets(doc.Content()))
 	}
 
 	b, err := json.Marshal(docs)
@@ -160,7 +165,7 @@ func (sc *store) GetHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas
 	if err != nil {
 		common.HttpResponse(ctx, "error retrieving document "+id, 404)
 	} else {
-		b, err := json.Marshal(doc.Content())
+		b, err := json.Marshal(redactSecrets(doc.Content()))
 		if err != nil {
 			return common.HttpResponse(ctx, err.Error(), 400)
 		}
@@ -180,7 +185,7 @@ func (sc *store) PutHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas
 
 	id := params["name"]
 
-	_, err := teamCol.Doc(id).Get()
+	existing, err := teamCol.Doc(id).Get()
 	if err != nil {
 		ctx.Response.Body = []byte("Error retrieving document " + id)
 		ctx.Response.Status = 404
@@ -189,6 +194,11 @@ func (sc *store) PutHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas
 		if err := json.Unmarshal(ctx.Request.Data(), store); err != nil {
 			return common.HttpResponse(ctx, "error decoding json body", 400)
 		}
+		keepSecrets(store, existing.Content())
+
+		if err := store.Validate(); err != nil {
+			return common.HttpResponse(ctx, "invalid team configuration: "+err.Error(), 400)
+		}
 
 		// Convert the document to a map[string]interface{}
 		// for storage, future iterations of the go-sdk may include direct interface{} storage as well
@@ -0,0 +1,61 @@
+package scrum
+
+import (
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// digestDays is how many days the digest rolls up.
+const digestDays = 7
+
+func reportHistoryID(team, date string) string {
+	return team + "@" + date
+}
+
+// saveReportHistory keeps the report so it can be rolled up in the digest.
+func (mod *service) saveReportHistory(r *report.Report) {
+	reportMap := map[string]interface{}{}
+	err := decodeWithJsonTags(r, &reportMap)
+	if err == nil {
+		err = reportHistoryCol.Doc(reportHistoryID(r.Team, r.Date)).Set(reportMap)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"team":  r.Team,
+			"date":  r.Date,
+			"error": err,
+		}).Warn("Failed to save the report history")
+	}
+}
+
+// SendDigestForTeam posts the roll-up of the team's reports over the last week.
+func (mod *service) SendDigestForTeam(tc *TeamConfig, sendTo string) error {
+	today, err := tc.DayIn(tc.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+
+	day, err := time.Parse(common.DateFormat, today)
+	if err != nil {
+		return err
+	}
+	reports, err := mod.reportsBetween(tc, day.AddDate(0, 0, -(digestDays-1)).Format(common.DateFormat), today)
+	if err != nil {
+		return err
+	}
+
+	d := report.NewDigest(tc.Name, reports)
+	mod.postMessageToSlack(sendTo, d.Header(), SlackParams, slack.MsgOptionAttachments(report.DigestAttachments(d)...))
+
+	log.WithFields(log.Fields{
+		"team":    tc.Name,
+		"channel": sendTo,
+		"days":    d.Days,
+	}).Info("Sent weekly digest.")
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package scrum
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// directoryTTL is how long the workspace's users are cached
+	directoryTTL = time.Hour
+	// directoryRetry is how long to wait before fetching the users again for a name that wasn't found
+	directoryRetry = 5 * time.Minute
+)
+
+// directory resolves member names to Slack user IDs, members are configured by their display
+// name but Slack only reliably notifies <@U123> mentions. It also keeps the users' timezones
+// by ID.
+type directory struct {
+	sync.Mutex
+	ids     map[string]string
+	zones   map[string]string
+	fetched time.Time
+}
+
+// directoryNames returns the other names a user can be configured as, lower cased.
+func directoryNames(u slack.User) []string {
+	names := []string{}
+	for _, name := range []string{u.Name, u.RealName, u.Profile.RealName} {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// index maps the users' names to their IDs. The display names are indexed first so one wins
+// over another user's username or real name, as that's what members are configured by.
+func index(users []slack.User) map[string]string {
+	ids := map[string]string{}
+	for _, u := range users {
+		if u.Deleted || u.IsBot {
+			continue
+		}
+		if name := strings.ToLower(strings.TrimSpace(u.Profile.DisplayName)); name != "" {
+			if _, taken := ids[name]; !taken {
+				ids[name] = u.ID
+			}
+		}
+	}
+	for _, u := range users {
+		if u.Deleted || u.IsBot {
+			continue
+		}
+		for _, name := range directoryNames(u) {
+			if _, taken := ids[name]; !taken {
+				ids[name] = u.ID
+			}
+		}
+	}
+	return ids
+}
+
+// timezones maps the users' IDs to the timezone set in their Slack profile.
+func timezones(users []slack.User) map[string]string {
+	zones := map[string]string{}
+	for _, u := range users {
+		if u.TZ != "" {
+			zones[u.ID] = u.TZ
+		}
+	}
+	return zones
+}
+
+// slackIDFor returns the member's Slack user ID, empty if there's no such user.
+func (mod *service) slackIDFor(username string) string {
+	id, _ := mod.lookupMember(username)
+	return id
+}
+
+// lookupMember returns the member's Slack user ID and timezone, empty if there's no such user.
+func (mod *service) lookupMember(username string) (string, string) {
+	mod.directory.Lock()
+	defer mod.directory.Unlock()
+
+	name := strings.ToLower(strings.TrimSpace(username))
+	id, found := mod.directory.ids[name]
+	age := time.Since(mod.directory.fetched)
+	if age < directoryTTL && (found || age < directoryRetry) {
+		return id, mod.directory.zones[id]
+	}
+
+	users, err := mod.slackBotAPI.GetUsers()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list the Slack users")
+		return id, mod.directory.zones[id]
+	}
+	mod.directory.ids = index(users)
+	mod.directory.zones = timezones(users)
+	mod.directory.fetched = time.Now()
+	id = mod.directory.ids[name]
+	return id, mod.directory.zones[id]
+}
+
+// resolveMember fills in the member's Slack user ID if it isn't known yet, and keeps their
+// timezone in line with their Slack profile so members who never talked to the bot are
+// still prompted in their own timezone.
+func (mod *service) resolveMember(us *UserState) {
+	if !us.fillFromSlack(mod.lookupMember(us.User)) {
+		return
+	}
+	if err := mod.SaveUserState(us); err != nil {
+		log.WithError(err).Warn("Failed to save the member's Slack details")
+	}
+}
+
+// fillFromSlack sets the member's Slack user ID if it isn't known and the timezone of that
+// user, it tells if anything changed.
+func (us *UserState) fillFromSlack(id, tz string) bool {
+	changed := false
+	if us.SlackID == "" && id != "" {
+		us.SlackID = id
+		changed = true
+	}
+	if tz != "" && tz != us.Timezone && us.SlackID == id {
+		us.Timezone = tz
+		changed = true
+	}
+	return changed
+}
+
+// mention returns how the member is mentioned in Slack.
+func (us *UserState) mention() string {
+	return report.Mention(us.User, us.SlackID)
+}
+
+// dmChannel returns where to send the member a direct message.
+func (us *UserState) dmChannel() string {
+	if us.SlackID != "" {
+		return us.SlackID
+	}
+	return "@" + us.User
+}
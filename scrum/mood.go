@@ -0,0 +1,151 @@
+package scrum
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/nitrictech/go-sdk/api/documents"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultMoodTrendDays is how many days the mood trend covers if the team didn't say.
+	defaultMoodTrendDays = 14
+	// minMoodResponses is how many scores the mood needs before it is shown, with fewer
+	// the aggregate gives away the members' own scores.
+	minMoodResponses = 3
+)
+
+// MoodScore is a member's answer to the team's mood question on a day.
+type MoodScore struct {
+	Team  string `json:"team"`
+	User  string `json:"user"`
+	Date  string `json:"date"`
+	Score int    `json:"score"`
+	// Private is whether the member keeps their mood private
+	Private bool `json:"private"`
+}
+
+// MoodScoreFor returns the member's answer to the mood question, false if there is none.
+func (tc *TeamConfig) MoodScoreFor(answers map[string]string) (int, bool) {
+	if tc.MoodQuestion == "" {
+		return 0, false
+	}
+	score, err := strconv.Atoi(answers[tc.MoodQuestion])
+	if err != nil || score < scaleMin || score > scaleMax {
+		return 0, false
+	}
+	return score, true
+}
+
+// publicAnswers leaves the mood out of the member's answers if they keep it private.
+func (tc *TeamConfig) publicAnswers(us *UserState, answers map[string]string) map[string]string {
+	if !us.MoodPrivate || tc.MoodQuestion == "" {
+		return answers
+	}
+	public := map[string]string{}
+	for q, a := range answers {
+		if q != tc.MoodQuestion {
+			public[q] = a
+		}
+	}
+	return public
+}
+
+// moodSummary aggregates the scores, nil if there are too few to keep them anonymous: fewer
+// than minMoodResponses, or a single private score that the public ones would give away.
+func moodSummary(scores []int, private int) *report.Mood {
+	if len(scores) < minMoodResponses || private == 1 {
+		return nil
+	}
+
+	m := &report.Mood{
+		Responses:    len(scores),
+		Min:          scaleMin,
+		Max:          scaleMax,
+		Distribution: make([]int, scaleMax-scaleMin+1),
+		Trend:        []report.MoodPoint{},
+	}
+	total := 0
+	for _, s := range scores {
+		total += s
+		m.Distribution[s-scaleMin]++
+	}
+	m.Average = float64(total) / float64(len(scores))
+	return m
+}
+
+func (tc *TeamConfig) moodTrendDays() int {
+	if tc.MoodTrendDays > 0 {
+		return tc.MoodTrendDays
+	}
+	return defaultMoodTrendDays
+}
+
+func (mod *service) saveMoodScore(tc *TeamConfig, us *UserState, date string) {
+	score, ok := tc.MoodScoreFor(us.Answers)
+	if !ok {
+		return
+	}
+
+	ms := &MoodScore{Team: tc.Name, User: us.User, Date: date, Score: score, Private: us.MoodPrivate}
+	moodMap := map[string]interface{}{}
+	err := decodeWithJsonTags(ms, &moodMap)
+	if err == nil {
+		err = moodCol.Doc(fmt.Sprintf("%s@%s@%s", tc.Name, us.User, date)).Set(moodMap)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"team":  tc.Name,
+			"user":  us.User,
+			"error": err,
+		}).Warn("Failed to save mood score")
+	}
+}
+
+// MoodTrend returns the team's average mood by day, over the team's trend days up to today.
+func (mod *service) MoodTrend(tc *TeamConfig, today string) ([]report.MoodPoint, error) {
+	day, err := time.Parse(common.DateFormat, today)
+	if err != nil {
+		return nil, err
+	}
+	since := day.AddDate(0, 0, 1-tc.moodTrendDays()).Format(common.DateFormat)
+
+	results, err := moodCol.Query().Where(
+		documents.Condition("team").Eq(documents.StringValue(tc.Name)),
+		documents.Condition("date").Ge(documents.StringValue(since)),
+	).Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := map[string][]int{}
+	private := map[string]int{}
+	for _, doc := range results.Documents {
+		ms := &MoodScore{}
+		if err := decodeWithJsonTags(doc.Content(), ms); err != nil {
+			return nil, err
+		}
+		if ms.Date <= today {
+			byDate[ms.Date] = append(byDate[ms.Date], ms.Score)
+			if ms.Private {
+				private[ms.Date]++
+			}
+		}
+	}
+
+	trend := []report.MoodPoint{}
+	for date, scores := range byDate {
+		m := moodSummary(scores, private[date])
+		if m == nil {
+			continue
+		}
+		trend = append(trend, report.MoodPoint{Date: date, Average: m.Average, Responses: m.Responses})
+	}
+	sort.Slice(trend, func(i, j int) bool { return trend[i].Date < trend[j].Date })
+	return trend, nil
+}
@@ -0,0 +1,120 @@
+package scrum
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asalkeld/scrumpolice/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// NonResponderPolicy is what to do about the members that didn't hand in their scrum
+// when the report goes out.
+type NonResponderPolicy string
+
+const (
+	// MentionNonResponders @-mentions them under the report (the default)
+	MentionNonResponders NonResponderPolicy = "mention"
+	// ListNonResponders lists them under the report without notifying them
+	ListNonResponders NonResponderPolicy = "list"
+	// DMNonResponders sends each of them a direct message
+	DMNonResponders NonResponderPolicy = "dm"
+	// AdminNonResponders sends the list to the team admin only
+	AdminNonResponders NonResponderPolicy = "admin"
+	// IgnoreNonResponders does nothing
+	IgnoreNonResponders NonResponderPolicy = "none"
+)
+
+func (tc *TeamConfig) nonResponderPolicy() NonResponderPolicy {
+	if tc.NonResponderPolicy == "" {
+		return MentionNonResponders
+	}
+	return tc.NonResponderPolicy
+}
+
+// nonResponderGrace is how many reports in a row a member has to miss before the policy applies.
+func (tc *TeamConfig) nonResponderGrace() int {
+	if tc.NonResponderGrace < 1 {
+		return 1
+	}
+	return tc.NonResponderGrace
+}
+
+// countMissed keeps track of how many reports in a row each member missed.
+func (mod *service) countMissed(members []*UserState, nonResponders []string) {
+	missed := map[string]bool{}
+	for _, user := range nonResponders {
+		missed[user] = true
+	}
+
+	for _, member := range members {
+		count := 0
+		if missed[member.User] {
+			count = member.MissedReports + 1
+		} else if member.OutOfOffice {
+			// being away doesn't break or extend the streak
+			continue
+		}
+		if count == member.MissedReports {
+			continue
+		}
+		member.MissedReports = count
+		if err := mod.SaveUserState(member); err != nil {
+			log.WithError(err).Warn("Failed to save missed reports")
+		}
+	}
+}
+
+// overGrace returns the non responders who missed at least the team's grace of reports in a
+// row, counted tells if today's report was already counted.
+func (tc *TeamConfig) overGrace(members []*UserState, nonResponders []string, counted bool) []string {
+	missed := map[string]int{}
+	for _, member := range members {
+		missed[member.User] = member.MissedReports
+		if !counted {
+			missed[member.User]++
+		}
+	}
+
+	over := []string{}
+	for _, user := range nonResponders {
+		if missed[user] >= tc.nonResponderGrace() {
+			over = append(over, user)
+		}
+	}
+	return over
+}
+
+// sendNonResponders applies the team's policy to the non responders and returns the timestamp
+// of the message under the report, if one was posted.
+func (mod *service) sendNonResponders(tc *TeamConfig, sendTo string, ref ReportRef, r *report.Report, users []string) string {
+	policy := tc.nonResponderPolicy()
+	if len(users) == 0 || (strings.HasPrefix(sendTo, "@") && (policy == DMNonResponders || policy == AdminNonResponders)) {
+		// don't chase anyone from a preview
+		return ""
+	}
+
+	switch policy {
+	case MentionNonResponders, ListNonResponders:
+		text, opts := tc.nonRespondersMessage(r, users)
+		if ref.Mode == ThreadMode {
+			// keep the channel to the summary
+			_, ts := mod.postMessageToSlack(ref.Channel, text, append(opts, SlackParams, slack.MsgOptionTS(ref.Timestamp))...)
+			return ts
+		}
+		_, ts := mod.postMessageToSlack(sendTo, text, append(opts, SlackParams)...)
+		return ts
+	case DMNonResponders:
+		for _, user := range users {
+			mod.postMessageToSlack(mod.dmTarget(user), fmt.Sprintf("The scrum report for team %s went out without yours. Tell me `start` to add it late.", tc.Name), SlackParams)
+		}
+	case AdminNonResponders:
+		if tc.Admin == "" {
+			log.WithField("team", tc.Name).Warn("No admin to send the non responders to")
+			return ""
+		}
+		mod.postMessageToSlack(mod.dmTarget(tc.Admin), fmt.Sprintf("These members of team %s didn't hand in their scrum for %s: %s", tc.Name, ref.Date, strings.Join(mentions(r, users), ", ")), SlackParams)
+	}
+	return ""
+}
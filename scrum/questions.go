@@ -0,0 +1,363 @@
+package scrum
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type QuestionType string
+
+const (
+	TextQuestion   QuestionType = "text"
+	YesNoQuestion  QuestionType = "yesno"
+	ChoiceQuestion QuestionType = "choice"
+	ScaleQuestion  QuestionType = "scale"
+
+	scaleMin = 1
+	scaleMax = 5
+
+	// defaultAnswerDoneEmoji and defaultAnswerTimeoutMinutes end an answer in multi-message
+	// mode if the team didn't say
+	defaultAnswerDoneEmoji      = "white_check_mark"
+	defaultAnswerTimeoutMinutes = 10
+)
+
+// Question is a question of the scrum, a plain string in the configuration is a free text
+// question. Answers are keyed by the question's text.
+type Question struct {
+	Text    string       `json:"text"`
+	Type    QuestionType `json:"type"`
+	Choices []string     `json:"choices"`
+	When    Condition    `json:"when"`
+	// PlannedIn is the question the previous answer of which is the plan for this one,
+	// e.g. "What will you do today?" for "What did you do yesterday?"
+	PlannedIn string `json:"plannedIn"`
+}
+
+// Condition makes a question a follow-up, only asked if an earlier question got the answer.
+type Condition struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// IsSet tells if there is a condition.
+func (c *Condition) IsSet() bool {
+	return c.Question != ""
+}
+
+var (
+	yesAnswers = map[string]bool{"yes": true, "y": true, "yep": true, "yeah": true, "true": true}
+	noAnswers  = map[string]bool{"no": true, "n": true, "nope": true, "nah": true, "false": true}
+
+	scaleEmojis = []string{":disappointed:", ":slightly_frowning_face:", ":neutral_face:", ":slightly_smiling_face:", ":star-struck:"}
+)
+
+func (q *Question) UnmarshalJSON(b []byte) error {
+	text := ""
+	if err := json.Unmarshal(b, &text); err == nil {
+		*q = Question{Text: text}
+		return nil
+	}
+
+	type question Question
+	return json.Unmarshal(b, (*question)(q))
+}
+
+// questionDecodeHook lets mapstructure decode the plain string questions of older configurations.
+func questionDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to == reflect.TypeOf(Question{}) && from.Kind() == reflect.String {
+		return Question{Text: data.(string)}, nil
+	}
+	return data, nil
+}
+
+// Validate checks the question has a known type and a choice question has choices.
+func (q *Question) Validate() error {
+	if strings.TrimSpace(q.Text) == "" {
+		return fmt.Errorf("a question has no text")
+	}
+
+	switch q.Type {
+	case "", TextQuestion, YesNoQuestion, ScaleQuestion:
+	case ChoiceQuestion:
+		if len(q.Choices) == 0 {
+			return fmt.Errorf("question %q has no choices", q.Text)
+		}
+	default:
+		return fmt.Errorf("question %q has an unknown type %q", q.Text, q.Type)
+	}
+	return nil
+}
+
+// IsFreeText tells if any text is an answer to the question.
+func (q *Question) IsFreeText() bool {
+	return q.Type == "" || q.Type == TextQuestion
+}
+
+// Answers returns the accepted answers, as Parse returns them, nil for a free text question.
+func (q *Question) Answers() []string {
+	switch q.Type {
+	case YesNoQuestion:
+		return []string{"yes", "no"}
+	case ChoiceQuestion:
+		return q.Choices
+	case ScaleQuestion:
+		answers := []string{}
+		for n := scaleMin; n <= scaleMax; n++ {
+			answers = append(answers, strconv.Itoa(n))
+		}
+		return answers
+	}
+	return nil
+}
+
+// Prompt is how the question is asked, with the expected answers when it isn't free text.
+func (q *Question) Prompt() string {
+	switch q.Type {
+	case YesNoQuestion:
+		return q.Text + " (yes/no)"
+	case ScaleQuestion:
+		return fmt.Sprintf("%s (%d-%d)", q.Text, scaleMin, scaleMax)
+	case ChoiceQuestion:
+		lines := []string{q.Text}
+		for i, c := range q.Choices {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, c))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return q.Text
+}
+
+// Parse validates the answer and returns it normalised, "yes"/"no" for yes/no questions,
+// the choice's text for choice questions and the number for scale questions.
+func (q *Question) Parse(answer string) (string, error) {
+	a := strings.ToLower(strings.Trim(strings.TrimSpace(answer), ".!"))
+
+	switch q.Type {
+	case YesNoQuestion:
+		if yesAnswers[a] {
+			return "yes", nil
+		}
+		if noAnswers[a] {
+			return "no", nil
+		}
+		return "", fmt.Errorf("please answer `yes` or `no`")
+	case ScaleQuestion:
+		n, err := strconv.Atoi(a)
+		if err != nil || n < scaleMin || n > scaleMax {
+			return "", fmt.Errorf("please answer with a number from %d to %d", scaleMin, scaleMax)
+		}
+		return strconv.Itoa(n), nil
+	case ChoiceQuestion:
+		if n, err := strconv.Atoi(a); err == nil && n >= 1 && n <= len(q.Choices) {
+			return q.Choices[n-1], nil
+		}
+		for _, c := range q.Choices {
+			if strings.ToLower(c) == a {
+				return c, nil
+			}
+		}
+		return "", fmt.Errorf("please answer with one of the choices or its number")
+	}
+	return answer, nil
+}
+
+// Format returns the answer as shown in Slack, scales as emoji.
+func (q *Question) Format(answer string) string {
+	plain := q.FormatPlain(answer)
+	switch q.Type {
+	case YesNoQuestion:
+		if answer == "yes" {
+			return ":white_check_mark: " + plain
+		}
+		if answer == "no" {
+			return ":x: " + plain
+		}
+	case ScaleQuestion:
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= scaleMin && n <= scaleMax {
+			return scaleEmojis[n-scaleMin] + " " + plain
+		}
+	}
+	return plain
+}
+
+// FormatPlain returns the answer as shown outside Slack, without the emoji.
+func (q *Question) FormatPlain(answer string) string {
+	switch q.Type {
+	case YesNoQuestion:
+		if answer == "yes" {
+			return "Yes"
+		}
+		if answer == "no" {
+			return "No"
+		}
+	case ScaleQuestion:
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= scaleMin && n <= scaleMax {
+			return fmt.Sprintf("%d/%d", n, scaleMax)
+		}
+	}
+	return answer
+}
+
+func (tc *TeamConfig) hasQuestion(text string) bool {
+	for _, q := range tc.Questions {
+		if q.Text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// QuestionTexts returns the text of the team's questions.
+func (tc *TeamConfig) QuestionTexts() []string {
+	texts := []string{}
+	for _, q := range tc.Questions {
+		texts = append(texts, q.Text)
+	}
+	return texts
+}
+
+// Applies tells if the question is asked given the answers so far, a follow-up is only
+// asked when the question it follows got the expected answer.
+func (tc *TeamConfig) Applies(q *Question, answers map[string]string) bool {
+	if !q.When.IsSet() {
+		return true
+	}
+	answer, answered := answers[q.When.Question]
+	return answered && strings.EqualFold(strings.TrimSpace(answer), strings.TrimSpace(q.When.Answer))
+}
+
+// NextQuestion returns the first question the member hasn't answered that applies to them,
+// nil if they are done.
+func (tc *TeamConfig) NextQuestion(us *UserState) *Question {
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if _, answered := us.Answers[q.Text]; answered || !tc.Applies(q, us.Answers) {
+			continue
+		}
+		return q
+	}
+	return nil
+}
+
+// DoneEmoji is the reaction, without colons, that ends an answer in multi-message mode.
+func (tc *TeamConfig) DoneEmoji() string {
+	if emoji := strings.Trim(tc.AnswerDoneEmoji, ": "); emoji != "" {
+		return emoji
+	}
+	return defaultAnswerDoneEmoji
+}
+
+// AnswerTimeout is how long after their last message a member's answer is taken as done in
+// multi-message mode.
+func (tc *TeamConfig) AnswerTimeout() time.Duration {
+	if tc.AnswerTimeoutMinutes > 0 {
+		return time.Duration(tc.AnswerTimeoutMinutes) * time.Minute
+	}
+	return defaultAnswerTimeoutMinutes * time.Minute
+}
+
+// DraftTimedOut tells if the member stopped writing the answer they are drafting in
+// multi-message mode for longer than the answer timeout.
+func (tc *TeamConfig) DraftTimedOut(us *UserState, now time.Time) bool {
+	if !tc.MultiMessageAnswers || !us.Started || us.Draft == "" {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339, us.DraftAt)
+	return err == nil && now.Sub(at) >= tc.AnswerTimeout()
+}
+
+// Answered returns the questions that got an answer and still apply, in order.
+func (tc *TeamConfig) Answered(answers map[string]string) []*Question {
+	questions := []*Question{}
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if _, answered := answers[q.Text]; answered && tc.Applies(q, answers) {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
+
+// DropInapplicable removes the answers to follow-ups that no longer apply, after the
+// question they follow got another answer.
+func (tc *TeamConfig) DropInapplicable(answers map[string]string) {
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if !tc.Applies(q, answers) {
+			delete(answers, q.Text)
+		}
+	}
+}
+
+// Validate checks the team's questions, that follow-ups follow an earlier question and the
+// blocker question is one of them, the report mode and format, the non responder and
+// missed policies, that the mood question is a scale, and the report templates.
+func (tc *TeamConfig) Validate() error {
+	earlier := map[string]bool{}
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if err := q.Validate(); err != nil {
+			return err
+		}
+		if q.PlannedIn != "" && !tc.hasQuestion(q.PlannedIn) {
+			return fmt.Errorf("question %q is planned in %q which isn't a question", q.Text, q.PlannedIn)
+		}
+		if q.When.IsSet() && !earlier[q.When.Question] {
+			return fmt.Errorf("question %q follows %q which isn't an earlier question", q.Text, q.When.Question)
+		}
+		earlier[q.Text] = true
+	}
+	switch tc.NonResponderPolicy {
+	case "", MentionNonResponders, ListNonResponders, DMNonResponders, IgnoreNonResponders:
+	case AdminNonResponders:
+		if tc.Admin == "" {
+			return fmt.Errorf("the %s non responder policy needs an admin", AdminNonResponders)
+		}
+	default:
+		return fmt.Errorf("unknown non responder policy %q", tc.NonResponderPolicy)
+	}
+	if tc.BlockerQuestion != "" && !tc.hasQuestion(tc.BlockerQuestion) {
+		return fmt.Errorf("the blocker question %q isn't one of the questions", tc.BlockerQuestion)
+	}
+	switch tc.ReportMode {
+	case "", SingleMode, SplitMode, ThreadMode:
+	default:
+		return fmt.Errorf("unknown report mode %q", tc.ReportMode)
+	}
+	switch tc.ReportFormat {
+	case "", AttachmentsFormat, BlocksFormat:
+	default:
+		return fmt.Errorf("unknown report format %q", tc.ReportFormat)
+	}
+	switch tc.MissedPolicy {
+	case "", MissedSendLate, MissedSkip:
+	case MissedSendToAdmin:
+		if tc.Admin == "" {
+			return fmt.Errorf("the %s missed policy needs an admin", MissedSendToAdmin)
+		}
+	default:
+		return fmt.Errorf("unknown missed policy %q", tc.MissedPolicy)
+	}
+	if tc.MoodQuestion != "" {
+		found := false
+		for _, q := range tc.Questions {
+			if q.Text == tc.MoodQuestion {
+				found = q.Type == ScaleQuestion
+			}
+		}
+		if !found {
+			return fmt.Errorf("the mood question %q must be one of the scale questions", tc.MoodQuestion)
+		}
+	}
+	if err := tc.Templates.Validate(); err != nil {
+		return fmt.Errorf("invalid report templates: %w", err)
+	}
+	return nil
+}
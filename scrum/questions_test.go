@@ -0,0 +1,148 @@
+package scrum
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestQuestionsAcceptPlainStrings(t *testing.T) {
+	tc := TeamConfig{}
+	err := json.Unmarshal([]byte(`{"questions": ["Yesterday?", {"text": "Mood?", "type": "scale"}]}`), &tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tc.Questions) != 2 || tc.Questions[0].Text != "Yesterday?" || tc.Questions[1].Type != ScaleQuestion {
+		t.Errorf("unexpected questions %+v", tc.Questions)
+	}
+
+	stored := map[string]interface{}{"name": "L337", "questions": []interface{}{"Yesterday?", map[string]interface{}{"text": "Mood?", "type": "scale"}}}
+	tc = TeamConfig{}
+	if err := decodeWithJsonTags(stored, &tc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tc.Questions) != 2 || tc.Questions[0].Text != "Yesterday?" || tc.Questions[1].Type != ScaleQuestion {
+		t.Errorf("unexpected stored questions %+v", tc.Questions)
+	}
+}
+
+func TestQuestionParse(t *testing.T) {
+	for _, c := range []struct {
+		q        Question
+		answer   string
+		expected string
+		valid    bool
+	}{
+		{Question{Text: "Anything?"}, "whatever", "whatever", true},
+		{Question{Text: "Deployed?", Type: YesNoQuestion}, "Yep!", "yes", true},
+		{Question{Text: "Deployed?", Type: YesNoQuestion}, "maybe", "", false},
+		{Question{Text: "Mood?", Type: ScaleQuestion}, "4", "4", true},
+		{Question{Text: "Mood?", Type: ScaleQuestion}, "6", "", false},
+		{Question{Text: "Where?", Type: ChoiceQuestion, Choices: []string{"Office", "Home"}}, "2", "Home", true},
+		{Question{Text: "Where?", Type: ChoiceQuestion, Choices: []string{"Office", "Home"}}, "office", "Office", true},
+		{Question{Text: "Where?", Type: ChoiceQuestion, Choices: []string{"Office", "Home"}}, "Moon", "", false},
+	} {
+		answer, err := c.q.Parse(c.answer)
+		if (err == nil) != c.valid || answer != c.expected {
+			t.Errorf("%s %q: expected %q (valid %v), got %q (%v)", c.q.Type, c.answer, c.expected, c.valid, answer, err)
+		}
+	}
+}
+
+func TestQuestionFormat(t *testing.T) {
+	scale := Question{Text: "Mood?", Type: ScaleQuestion}
+	if f := scale.Format("5"); f != ":star-struck: 5/5" {
+		t.Errorf("unexpected scale %q", f)
+	}
+	yesno := Question{Text: "Deployed?", Type: YesNoQuestion}
+	if f := yesno.Format("no"); f != ":x: No" {
+		t.Errorf("unexpected yes/no %q", f)
+	}
+	if f := scale.FormatPlain("5"); f != "5/5" {
+		t.Errorf("unexpected plain scale %q", f)
+	}
+	if f := yesno.FormatPlain("no"); f != "No" {
+		t.Errorf("unexpected plain yes/no %q", f)
+	}
+}
+
+func TestFollowUpOnlyAskedOnAnswer(t *testing.T) {
+	tc := TeamConfig{Questions: []Question{
+		{Text: "Are you blocked?", Type: YesNoQuestion},
+		{Text: "By whom and on what?", When: Condition{Question: "Are you blocked?", Answer: "yes"}},
+		{Text: "Today?"},
+	}}
+	if err := tc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	us := &UserState{Answers: map[string]string{"Are you blocked?": "no"}}
+	if q := tc.NextQuestion(us); q == nil || q.Text != "Today?" {
+		t.Errorf("expected the follow-up to be skipped, got %+v", q)
+	}
+	us.Answers["Today?"] = "crowbar"
+	if q := tc.NextQuestion(us); q != nil || !us.HasReported("", &tc) {
+		t.Errorf("expected to be done, got %+v", q)
+	}
+
+	us.Answers["Are you blocked?"] = "yes"
+	if q := tc.NextQuestion(us); q == nil || q.Text != "By whom and on what?" {
+		t.Errorf("expected the follow-up, got %+v", q)
+	}
+
+	us.Answers["By whom and on what?"] = "the combine"
+	if answered := tc.Answered(us.Answers); len(answered) != 3 {
+		t.Errorf("expected 3 answered questions, got %d", len(answered))
+	}
+	us.Answers["Are you blocked?"] = "no"
+	tc.DropInapplicable(us.Answers)
+	if _, ok := us.Answers["By whom and on what?"]; ok || len(us.Answers) != 2 {
+		t.Errorf("expected the follow-up answer to be dropped, got %v", us.Answers)
+	}
+
+	tc.Questions[0], tc.Questions[1] = tc.Questions[1], tc.Questions[0]
+	if err := tc.Validate(); err == nil {
+		t.Error("expected a follow-up of a later question to be invalid")
+	}
+}
+
+func TestStuckItems(t *testing.T) {
+	plans := []string{
+		"- fix the crowbar\n- read mail",
+		"* Fix the crowbar.\n* headcrabs",
+		"- fix the  crowbar\n- headcrabs\n- lunch",
+	}
+	stuck := stuckItems(plans)
+	if len(stuck) != 1 || stuck[0] != "fix the  crowbar" {
+		t.Errorf("expected the crowbar to be stuck, got %v", stuck)
+	}
+}
+
+func TestMultiMessageDefaults(t *testing.T) {
+	tc := TeamConfig{}
+	if tc.DoneEmoji() != "white_check_mark" || tc.AnswerTimeout() != 10*time.Minute {
+		t.Errorf("unexpected defaults %s %s", tc.DoneEmoji(), tc.AnswerTimeout())
+	}
+
+	tc = TeamConfig{AnswerDoneEmoji: ":ok:", AnswerTimeoutMinutes: 3}
+	if tc.DoneEmoji() != "ok" || tc.AnswerTimeout() != 3*time.Minute {
+		t.Errorf("expected the team's settings, got %s %s", tc.DoneEmoji(), tc.AnswerTimeout())
+	}
+}
+
+func TestDraftTimedOut(t *testing.T) {
+	tc := TeamConfig{MultiMessageAnswers: true, AnswerTimeoutMinutes: 5}
+	now := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	us := &UserState{Started: true, Draft: "fixed the portal", DraftAt: now.Add(-4 * time.Minute).Format(time.RFC3339)}
+
+	if tc.DraftTimedOut(us, now) {
+		t.Errorf("expected the draft to still be open")
+	}
+	if !tc.DraftTimedOut(us, now.Add(time.Minute)) {
+		t.Errorf("expected the draft to have timed out")
+	}
+	us.Draft = ""
+	if tc.DraftTimedOut(us, now.Add(time.Hour)) {
+		t.Errorf("expected no draft to time out")
+	}
+}
@@ -0,0 +1,152 @@
+package scrum
+
+import (
+	"time"
+
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/slack-go/slack"
+)
+
+const (
+	AttachmentsFormat = "attachments"
+	BlocksFormat      = "blocks"
+
+	// FillInAction is the action of the button that opens the scrum form
+	FillInAction = "fill_in_scrum"
+)
+
+// UsesBlocks tells if the team wants its report as Block Kit rather than attachments.
+func (tc *TeamConfig) UsesBlocks() bool {
+	return tc.ReportFormat == BlocksFormat
+}
+
+// reportMessage is a message of the report, users are the members whose entries it holds.
+type reportMessage struct {
+	text    string
+	options []slack.MsgOption
+	users   []string
+}
+
+func entryUsers(r *report.Report) []string {
+	users := []string{}
+	for _, entry := range r.Entries {
+		users = append(users, entry.User)
+	}
+	return users
+}
+
+// reportMessages splits the report in the messages it is posted as, in the team's format and
+// mode. In thread mode the messages after the first are replies to it.
+func (tc *TeamConfig) reportMessages(r *report.Report) []reportMessage {
+	if tc.Mode() == ThreadMode && r.Stats != nil {
+		// the stats are the summary in the thread's header, they aren't repeated at the bottom
+		inThread := *r
+		inThread.Stats = nil
+		r = &inThread
+	}
+	if tc.UsesBlocks() {
+		return blocksMessages(tc.Mode(), r)
+	}
+	return attachmentsMessages(tc.Mode(), r)
+}
+
+func blocksMessages(mode string, r *report.Report) []reportMessage {
+	blocks := report.Blocks(r)
+	messages := []reportMessage{}
+	add := func(text string, users []string, group []slack.Block) {
+		for _, b := range report.PackBlocks([][]slack.Block{group}) {
+			messages = append(messages, reportMessage{text: text, options: []slack.MsgOption{slack.MsgOptionBlocks(b...)}, users: users})
+		}
+	}
+
+	switch mode {
+	case SplitMode, ThreadMode:
+		header := blocks.Header
+		if mode == ThreadMode {
+			header = append(append([]slack.Block{}, blocks.Header...), report.ContextBlocks(r.Summary)...)
+		}
+		add(r.Header, nil, header)
+		for i, entry := range blocks.Entries {
+			add(r.EntryTitle, []string{r.Entries[i].User}, entry)
+		}
+		add(r.EntryTitle, nil, blocks.Footer)
+	default:
+		for i, b := range blocks.Messages() {
+			m := reportMessage{text: r.EntryTitle, options: []slack.MsgOption{slack.MsgOptionBlocks(b...)}}
+			if i == 0 {
+				// which message an entry lands in isn't tracked, the report is edited as a whole
+				m.text, m.users = r.Header, entryUsers(r)
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func attachmentsMessages(mode string, r *report.Report) []reportMessage {
+	attachments := report.Attachments(r)
+	if mode != SplitMode && mode != ThreadMode {
+		return []reportMessage{{text: r.Header, options: []slack.MsgOption{slack.MsgOptionAttachments(attachments...)}, users: entryUsers(r)}}
+	}
+
+	header := reportMessage{text: r.Header}
+	if mode == ThreadMode {
+		header.text = r.Header + "\n" + r.Summary
+	}
+	if len(r.Blockers) > 0 {
+		// the blockers go on top with the header
+		header.options = []slack.MsgOption{slack.MsgOptionAttachments(attachments[0])}
+		attachments = attachments[1:]
+	}
+
+	messages := []reportMessage{header}
+	for i, a := range attachments {
+		m := reportMessage{text: r.EntryTitle, options: []slack.MsgOption{slack.MsgOptionAttachments(a)}}
+		if i < len(r.Entries) {
+			m.users = []string{r.Entries[i].User}
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// entryMessage returns the message options of a single member's entry in the team's
+// format, nil if they have no entry.
+func (tc *TeamConfig) entryMessage(at time.Time, us *UserState) []slack.MsgOption {
+	r := tc.BuildReport(at, []*UserState{us})
+	if len(r.Entries) == 0 {
+		return nil
+	}
+
+	if tc.UsesBlocks() {
+		return []slack.MsgOption{slack.MsgOptionBlocks(report.EntryBlocks(r.Entries[0])...)}
+	}
+	return []slack.MsgOption{slack.MsgOptionAttachments(report.EntryAttachment(r.Entries[0]))}
+}
+
+// nonRespondersMessage returns the text and message options of the non-responder line,
+// the users are @-mentioned unless the team only lists them.
+func (tc *TeamConfig) nonRespondersMessage(r *report.Report, users []string) (string, []slack.MsgOption) {
+	text := "And lastly, everyone handed in their scrum report :tada:"
+	if len(users) > 0 && tc.nonResponderPolicy() == ListNonResponders {
+		text = tc.RenderNonResponders(r.Date, users)
+	} else if len(users) > 0 {
+		text = tc.RenderNonResponders(r.Date, mentions(r, users))
+	}
+
+	if tc.UsesBlocks() {
+		return text, []slack.MsgOption{slack.MsgOptionBlocks(report.ContextBlocks(text)...)}
+	}
+	return text, nil
+}
+
+// fillInMessage shows the text with a button to fill in the scrum in a form, rather than
+// answering the questions one by one.
+func (tc *TeamConfig) fillInMessage(text string) slack.MsgOption {
+	button := slack.NewButtonBlockElement(FillInAction, tc.Name, slack.NewTextBlockObject(slack.PlainTextType, "Fill in standup", false, false))
+	button.Style = slack.StylePrimary
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", button),
+	)
+}
@@ -0,0 +1,48 @@
+package scrum
+
+import (
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/nitrictech/go-sdk/faas"
+)
+
+// ReportHandler renders the team's report as it stands now, the format query parameter
+// picks the renderer (json by default).
+func (mod *service) ReportHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error) {
+	params := ctx.Request.PathParams()
+	if len(params) == 0 {
+		return common.HttpResponse(ctx, "error retrieving path params", 400)
+	}
+
+	format := "json"
+	if f, ok := ctx.Request.Query()["format"]; ok && len(f) > 0 && f[0] != "" {
+		format = f[0]
+	}
+
+	renderer, err := report.Get(format)
+	if err != nil {
+		return common.HttpResponse(ctx, err.Error(), 400)
+	}
+
+	tc, err := mod.GetTeamByName(params["name"])
+	if err != nil {
+		return common.HttpResponse(ctx, "error retrieving team "+params["name"], 404)
+	}
+
+	members, err := mod.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return common.HttpResponse(ctx, "error retrieving team members: "+err.Error(), 500)
+	}
+
+	b, err := renderer.Render(mod.buildReport(tc, time.Now(), members))
+	if err != nil {
+		return common.HttpResponse(ctx, "error rendering report: "+err.Error(), 500)
+	}
+
+	ctx.Response.Body = b
+	ctx.Response.Headers["Content-Type"] = []string{renderer.ContentType()}
+
+	return next(ctx)
+}
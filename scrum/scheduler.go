@@ -0,0 +1,413 @@
+package scrum
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/robfig/cron"
+	log "github.com/sirupsen/logrus"
+)
+
+type EventKind string
+
+const (
+	ReportEvent   EventKind = "report"
+	PromptEvent   EventKind = "prompt"
+	ReminderEvent EventKind = "reminder"
+	DigestEvent   EventKind = "digest"
+
+	// maxSlotsPerScan stops a very frequent schedule from spinning forever
+	maxSlotsPerScan = 10000
+
+	heartbeatRate     = "30 minutes"
+	heartbeatInterval = 30 * time.Minute
+	// missedAfter is how late an event can run before it is considered missed, neither
+	// the timer nor the heartbeat picked it up in time. It is well over the heartbeat so an
+	// event only the heartbeat picked up isn't taken as missed.
+	missedAfter = 2 * heartbeatInterval
+)
+
+var EventKinds = []EventKind{PromptEvent, ReminderEvent, ReportEvent, DigestEvent}
+
+// ScheduleFor returns the cron expression configured for the given kind of event, empty if none.
+func (tc *TeamConfig) ScheduleFor(kind EventKind) string {
+	switch kind {
+	case ReportEvent:
+		return tc.ReportScheduleCron
+	case PromptEvent:
+		return tc.PromptScheduleCron
+	case ReminderEvent:
+		return tc.ReminderScheduleCron
+	case DigestEvent:
+		return tc.DigestScheduleCron
+	}
+	return ""
+}
+
+// TimezonesFor returns the timezones the event runs in, the report and digest follow the
+// team's timezone while prompts and reminders follow each member's.
+func (tc *TeamConfig) TimezonesFor(kind EventKind, memberZones []string) []string {
+	if kind == ReportEvent || kind == DigestEvent || len(memberZones) == 0 {
+		return []string{tc.Timezone}
+	}
+	return memberZones
+}
+
+// NextSlot returns the first time after "after" that the event is scheduled in the timezone,
+// zero if the team has no such schedule.
+func (tc *TeamConfig) NextSlot(kind EventKind, tz string, after time.Time) (time.Time, error) {
+	spec := tc.ScheduleFor(kind)
+	if spec == "" {
+		return time.Time{}, nil
+	}
+
+	c, err := cron.ParseStandard(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := common.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return c.Next(after.In(loc)), nil
+}
+
+// LastSlot returns the latest time in (since, now] that the event was scheduled in the timezone,
+// zero if there was none.
+func (tc *TeamConfig) LastSlot(kind EventKind, tz string, since, now time.Time) (time.Time, error) {
+	slots, err := tc.Slots(kind, tz, since, now)
+	if err != nil || len(slots) == 0 {
+		return time.Time{}, err
+	}
+	return slots[len(slots)-1], nil
+}
+
+// Slots returns every time in (since, now] that the event was scheduled in the timezone, oldest first.
+func (tc *TeamConfig) Slots(kind EventKind, tz string, since, now time.Time) ([]time.Time, error) {
+	slots := []time.Time{}
+	next, err := tc.NextSlot(kind, tz, since)
+	if err != nil || next.IsZero() {
+		return slots, err
+	}
+
+	for i := 0; i < maxSlotsPerScan && !next.After(now); i++ {
+		slots = append(slots, next)
+		next, err = tc.NextSlot(kind, tz, next)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return slots, nil
+}
+
+// Scheduler wakes up at the minute the next report, prompt or reminder is due
+// across all the teams. The nitric schedule only calls Tick as a heartbeat in case
+// the timer was missed (e.g. the function was frozen).
+type Scheduler struct {
+	service *service
+
+	lock    sync.Mutex
+	timer   *time.Timer
+	next    time.Time
+	started time.Time
+	// lastRun is keyed by team then by runKey
+	lastRun map[string]map[string]time.Time
+}
+
+func newScheduler(s *service) *Scheduler {
+	return &Scheduler{
+		service: s,
+		started: time.Now(),
+		lastRun: map[string]map[string]time.Time{},
+	}
+}
+
+// runKey identifies an event, prompts and reminders run once per member timezone.
+func runKey(kind EventKind, tz string) string {
+	if kind == ReportEvent || kind == DigestEvent {
+		return string(kind)
+	}
+	return string(kind) + "@" + tz
+}
+
+// lastRunsFor returns when the team's events last ran, from the store if they're not cached.
+func (s *Scheduler) lastRunsFor(tc *TeamConfig) map[string]time.Time {
+	if runs, ok := s.lastRun[tc.Name]; ok {
+		return runs
+	}
+
+	runs := map[string]time.Time{}
+	s.lastRun[tc.Name] = runs
+
+	doc, err := lastRunCol.Doc(tc.Name).Get()
+	if err != nil {
+		return runs
+	}
+	for k, v := range doc.Content() {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, str); err == nil {
+			runs[k] = t
+		}
+	}
+	return runs
+}
+
+func (s *Scheduler) saveLastRun(tc *TeamConfig, key string, t time.Time) error {
+	runs := s.lastRunsFor(tc)
+	runs[key] = t
+
+	doc := map[string]interface{}{}
+	for k, last := range runs {
+		doc[k] = last.Format(time.RFC3339)
+	}
+	return lastRunCol.Doc(tc.Name).Set(doc)
+}
+
+// NextDue returns the soonest event after "after" across all the teams, zero if nothing is scheduled.
+// memberZones holds the timezones of each team's members by team name.
+func NextDue(teams []TeamConfig, memberZones map[string][]string, after time.Time) time.Time {
+	next := time.Time{}
+	for i := range teams {
+		for _, kind := range EventKinds {
+			for _, tz := range teams[i].TimezonesFor(kind, memberZones[teams[i].Name]) {
+				slot, err := teams[i].NextSlot(kind, tz, after)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"team":     teams[i].Name,
+						"event":    kind,
+						"timezone": tz,
+						"error":    err,
+					}).Warn("Invalid schedule")
+					continue
+				}
+				if !slot.IsZero() && (next.IsZero() || slot.Before(next)) {
+					next = slot
+				}
+			}
+		}
+	}
+	return next
+}
+
+// Reschedule arms the timer for the next event due across all the teams.
+func (s *Scheduler) Reschedule() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rescheduleLocked(time.Now())
+}
+
+func (s *Scheduler) rescheduleLocked(now time.Time) {
+	cfg := s.service.configurationProvider.Config()
+	if cfg == nil {
+		return
+	}
+
+	memberZones := map[string][]string{}
+	for i := range cfg.Teams {
+		memberZones[cfg.Teams[i].Name] = s.service.MemberTimezones(&cfg.Teams[i])
+	}
+
+	next := NextDue(cfg.Teams, memberZones, now)
+	if next.IsZero() || next.Equal(s.next) {
+		return
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.next = next
+	s.timer = time.AfterFunc(time.Until(next), func() {
+		if err := s.Tick(time.Now()); err != nil {
+			log.WithError(err).Warn("Scheduled run returned an error")
+		}
+	})
+
+	log.WithField("next", next).Info("Scheduler armed.")
+}
+
+// Tick runs every report, prompt and reminder due since it last ran, finishes the answers
+// members stopped writing and re-arms the timer.
+func (s *Scheduler) Tick(now time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	errs := []error{}
+	// read the teams from the store as the cached config can have a stale lastSendDate
+	teams, err := s.service.GetAllTeams()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, tc := range teams {
+		finished, err := s.service.finishStaleDrafts(tc, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if finished {
+			// the last entry can send the report, read the team again for its lastSendDate
+			if tc, err = s.service.GetTeamByName(tc.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		memberZones := s.service.MemberTimezones(tc)
+		for _, kind := range EventKinds {
+			for _, tz := range tc.TimezonesFor(kind, memberZones) {
+				if err := s.runIfDue(tc, kind, tz, now); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	s.next = time.Time{}
+	s.rescheduleLocked(now)
+
+	return joinErrors(errs)
+}
+
+func (s *Scheduler) runIfDue(tc *TeamConfig, kind EventKind, tz string, now time.Time) error {
+	key := runKey(kind, tz)
+	since, ok := s.lastRunsFor(tc)[key]
+	if !ok {
+		since = s.started
+		if kind == ReportEvent {
+			// the report is only sent once a day, so catch up on today's if we started late
+			start, err := tc.StartOfDay(tc.Timezone, now)
+			if err != nil {
+				return err
+			}
+			since = start
+		}
+	}
+
+	slots, err := tc.Slots(kind, tz, since, now)
+	if err != nil || len(slots) == 0 {
+		return err
+	}
+	if kind != ReportEvent {
+		// only the latest prompt, reminder or digest is worth sending, each report is
+		// for its own day so none are dropped
+		slots = slots[len(slots)-1:]
+	}
+
+	for _, slot := range slots {
+		if err := s.runSlot(tc, kind, tz, slot, now); err != nil {
+			// the last run isn't saved so the slot is tried again on the next tick
+			return err
+		}
+		if err := s.saveLastRun(tc, key, slot); err != nil {
+			log.WithError(err).Warn("Failed to save the last run")
+		}
+	}
+	return nil
+}
+
+// runSlot runs the event scheduled at slot, through the missed policy if it is too late.
+func (s *Scheduler) runSlot(tc *TeamConfig, kind EventKind, tz string, slot, now time.Time) error {
+	if now.Sub(slot) > missedAfter {
+		return s.catchUp(tc, kind, tz, slot, now)
+	}
+
+	log.WithFields(log.Fields{
+		"team":     tc.Name,
+		"event":    kind,
+		"timezone": tz,
+		"slot":     slot,
+	}).Info("Running scheduled event.")
+
+	return s.run(tc, kind, tz)
+}
+
+func (s *Scheduler) run(tc *TeamConfig, kind EventKind, tz string) error {
+	switch kind {
+	case ReportEvent:
+		return s.service.SendReportForTeam(tc, tc.Channel)
+	case PromptEvent:
+		return s.service.SendPromptForTeam(tc, tz)
+	case ReminderEvent:
+		return s.service.SendReminderForTeam(tc, tz)
+	case DigestEvent:
+		return s.service.SendDigestForTeam(tc, tc.Channel)
+	}
+	return nil
+}
+
+// catchUp applies the team's missed policy to an event that should have run at slot.
+func (s *Scheduler) catchUp(tc *TeamConfig, kind EventKind, tz string, slot, now time.Time) error {
+	policy := tc.MissedPolicy
+	if policy == "" {
+		policy = MissedSendLate
+	}
+
+	log.WithFields(log.Fields{
+		"team":     tc.Name,
+		"event":    kind,
+		"timezone": tz,
+		"slot":     slot,
+		"policy":   policy,
+	}).Warn("Missed scheduled event.")
+
+	if policy == MissedSkip {
+		if kind != ReportEvent {
+			return nil
+		}
+		// don't let the next entry send it
+		if err := tc.markReportSent(slot); err != nil {
+			return err
+		}
+		return s.service.SaveTeamConfig(tc)
+	}
+
+	if kind != ReportEvent {
+		// asking for answers, or a digest, is only worth it on the same day
+		today, err := tc.DayIn(tz, now)
+		if err != nil {
+			return err
+		}
+		day, err := tc.DayIn(tz, slot)
+		if err != nil {
+			return err
+		}
+		if policy != MissedSendLate || day != today {
+			return nil
+		}
+		return s.run(tc, kind, tz)
+	}
+
+	note := fmt.Sprintf(":hourglass: This report was due %s, sorry it's late!", slot.Format("Mon Jan 2 15:04"))
+	if policy == MissedSendToAdmin {
+		if tc.Admin == "" {
+			return fmt.Errorf("team %s has no admin to send the missed report to", tc.Name)
+		}
+		if err := s.service.sendReport(tc, "@"+tc.Admin, slot, note); err != nil {
+			return err
+		}
+		// don't let the channel get it later on
+		if err := tc.markReportSent(slot); err != nil {
+			return err
+		}
+		return s.service.SaveTeamConfig(tc)
+	}
+	return s.service.sendReport(tc, tc.Channel, slot, note)
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msg := ""
+	for _, e := range errs {
+		msg += e.Error() + "\n"
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,105 @@
+package scrum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDuePicksSoonestEventAcrossTeams(t *testing.T) {
+	teams := []TeamConfig{
+		{Name: "a", Timezone: "UTC", ReportScheduleCron: "0 9 * * *"},
+		{Name: "b", Timezone: "UTC", ReportScheduleCron: "0 10 * * *", PromptScheduleCron: "30 8 * * *"},
+	}
+	now := time.Date(2022, 3, 21, 7, 0, 0, 0, time.UTC)
+
+	next := NextDue(teams, nil, now)
+	if !next.Equal(time.Date(2022, 3, 21, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next due %v", next)
+	}
+}
+
+func TestNextDueUsesTeamTimezone(t *testing.T) {
+	teams := []TeamConfig{
+		{Name: "a", Timezone: "Australia/Brisbane", ReportScheduleCron: "0 9 * * *"},
+	}
+	now := time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC)
+
+	next := NextDue(teams, nil, now)
+	if !next.Equal(time.Date(2022, 3, 21, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next due %v", next.UTC())
+	}
+}
+
+func TestLastSlotReturnsLatestSlotBeforeNow(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC", ReminderScheduleCron: "*/15 * * * *"}
+	since := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 3, 21, 9, 40, 0, 0, time.UTC)
+
+	slot, err := tc.LastSlot(ReminderEvent, tc.Timezone, since, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slot.Equal(time.Date(2022, 3, 21, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected slot %v", slot)
+	}
+}
+
+func TestSlotsReturnsEveryMissedSlot(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC", ReportScheduleCron: "0 9 * * *"}
+	since := time.Date(2022, 3, 18, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 3, 21, 9, 40, 0, 0, time.UTC)
+
+	slots, err := tc.Slots(ReportEvent, tc.Timezone, since, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 3 || !slots[0].Equal(time.Date(2022, 3, 19, 9, 0, 0, 0, time.UTC)) || !slots[2].Equal(time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the slots of the last 3 days, got %v", slots)
+	}
+}
+
+func TestLastSlotWithoutScheduleIsZero(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC"}
+	now := time.Date(2022, 3, 21, 9, 40, 0, 0, time.UTC)
+
+	slot, err := tc.LastSlot(PromptEvent, tc.Timezone, now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slot.IsZero() {
+		t.Errorf("expected no slot, got %v", slot)
+	}
+}
+
+func TestNextDuePromptsInMemberTimezones(t *testing.T) {
+	teams := []TeamConfig{
+		{Name: "a", Timezone: "Australia/Brisbane", ReportScheduleCron: "0 9 * * *", PromptScheduleCron: "0 8 * * *"},
+	}
+	zones := map[string][]string{"a": {"Australia/Brisbane", "America/Montreal"}}
+	now := time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC)
+
+	next := NextDue(teams, zones, now)
+	if !next.Equal(time.Date(2022, 3, 21, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next due %v", next.UTC())
+	}
+}
+
+func TestEntryAfterSkippedReportDoesNotSendIt(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC", ReportScheduleCron: "0 9 * * *", MissedPolicy: MissedSkip}
+	slot := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	// an entry comes in once the bot is back up, after the slot was missed
+	entry := slot.Add(3 * time.Hour)
+
+	if ready, err := tc.ReadyToSendReport(entry); err != nil || !ready {
+		t.Fatalf("expected the missed report to be due, got %v %v", ready, err)
+	}
+	if err := tc.markReportSent(slot); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := tc.ReadyToSendReport(entry); err != nil || ready {
+		t.Errorf("expected the skipped report not to be sent, got %v %v", ready, err)
+	}
+	if ready, _ := tc.ReadyToSendReport(slot.Add(24 * time.Hour)); !ready {
+		t.Errorf("expected the next day's report to be sent")
+	}
+}
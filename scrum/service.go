@@ -1,11 +1,13 @@
 package scrum
 
 import (
-	"errors"
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/email"
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/asalkeld/scrumpolice/webhook"
 	"github.com/nitrictech/go-sdk/api/documents"
 	"github.com/nitrictech/go-sdk/faas"
 	"github.com/nitrictech/go-sdk/resources"
@@ -34,23 +36,59 @@ type Service interface {
 	RemoveFromOutOfOffice(username string)
 
 	SendReportForTeam(tc *TeamConfig, sendTo string) error
+	SendLateEntry(tc *TeamConfig, us *UserState) error
+	EntrySubmitted(tc *TeamConfig, us *UserState)
+	OpenBlockers(team string) ([]*Blocker, error)
+	ResolveBlockers(tc *TeamConfig, username string, id string) ([]*Blocker, error)
+	PreviousAnswer(tc *TeamConfig, us *UserState, question string) (string, string)
+	SendPromptForTeam(tc *TeamConfig, tz string) error
+	SendReminderForTeam(tc *TeamConfig, tz string) error
+	SendDigestForTeam(tc *TeamConfig, sendTo string) error
+	MemberTimezones(tc *TeamConfig) []string
 	RunReports() error
+	// OnDraftTimeout registers what to do with a multi-message answer the member stopped writing
+	OnDraftTimeout(handler func(tc *TeamConfig, us *UserState))
+	// OnNotify registers who hands the webhook events on to DeliverWebhook, they are delivered
+	// straight away without one
+	OnNotify(publisher func(team, event string, data interface{}))
+	DeliverWebhook(team, event string, data interface{}) error
+
+	ReportHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error)
+	DeliveriesHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error)
+	StatsHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error)
 }
 
 type service struct {
 	configurationProvider ConfigurationProvider
 	slackBotAPI           *slack.Client
+	scheduler             *Scheduler
+	mailer                *email.Sender
+	webhooks              *webhook.Dispatcher
+	directory             directory
+
+	draftTimeoutHandlers []func(tc *TeamConfig, us *UserState)
+	notifyPublishers     []func(team, event string, data interface{})
 }
 
 var (
 	userStateCol documents.CollectionRef
+	lastRunCol   documents.CollectionRef
+	deliveryCol  documents.CollectionRef
+
+	reportHistoryCol documents.CollectionRef
+	blockerCol       documents.CollectionRef
+	moodCol          documents.CollectionRef
 )
 
-func NewService(configurationProvider ConfigurationProvider, slackBotAPI *slack.Client) (Service, error) {
+// NewService creates the scrum service, mailer can be nil if reports aren't emailed.
+func NewService(configurationProvider ConfigurationProvider, slackBotAPI *slack.Client, mailer *email.Sender) (Service, error) {
 	mod := &service{
 		configurationProvider: configurationProvider,
 		slackBotAPI:           slackBotAPI,
+		mailer:                mailer,
 	}
+	mod.scheduler = newScheduler(mod)
+	mod.webhooks = webhook.NewDispatcher(mod.logDelivery)
 
 	var err error
 	userStateCol, err = resources.NewCollection("userState", resources.CollectionWriting, resources.CollectionReading, resources.CollectionDeleting)
@@ -58,12 +96,39 @@ func NewService(configurationProvider ConfigurationProvider, slackBotAPI *slack.
 		return nil, err
 	}
 
-	err = resources.NewSchedule("sendReport", "30 minutes", func(ec *faas.EventContext, next faas.EventHandler) (*faas.EventContext, error) {
+	lastRunCol, err = resources.NewCollection("lastRun", resources.CollectionWriting, resources.CollectionReading)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryCol, err = resources.NewCollection("webhookDelivery", resources.CollectionWriting, resources.CollectionReading)
+	if err != nil {
+		return nil, err
+	}
+
+	reportHistoryCol, err = resources.NewCollection("reportHistory", resources.CollectionWriting, resources.CollectionReading)
+	if err != nil {
+		return nil, err
+	}
+
+	blockerCol, err = resources.NewCollection("blocker", resources.CollectionWriting, resources.CollectionReading)
+	if err != nil {
+		return nil, err
+	}
+
+	moodCol, err = resources.NewCollection("mood", resources.CollectionWriting, resources.CollectionReading)
+	if err != nil {
+		return nil, err
+	}
+
+	// the scheduler wakes up when the next event is due, this is only a heartbeat
+	// in case it missed it.
+	err = resources.NewSchedule("sendReport", heartbeatRate, func(ec *faas.EventContext, next faas.EventHandler) (*faas.EventContext, error) {
 		fmt.Println("Got scheduled event")
 
-		err := mod.RunReports()
+		err := mod.scheduler.Tick(time.Now())
 		if err != nil {
-			fmt.Println("RunReports returned an error ", err)
+			fmt.Println("Scheduler returned an error ", err)
 		}
 		return next(ec)
 	})
@@ -71,21 +136,68 @@ func NewService(configurationProvider ConfigurationProvider, slackBotAPI *slack.
 		return nil, err
 	}
 
+	configurationProvider.OnChange(func(cfg *Config) {
+		mod.scheduler.Reschedule()
+	})
+
 	return mod, nil
 }
 
-func (mod *service) postMessageToSlack(channel string, message string, params ...slack.MsgOption) {
-	_, _, err := mod.slackBotAPI.PostMessage(channel, append(params, slack.MsgOptionText(message, true))...)
+// postMessageToSlack returns the channel ID and timestamp of the posted message, empty on failure.
+func (mod *service) postMessageToSlack(channel string, message string, params ...slack.MsgOption) (string, string) {
+	channelID, ts, err := mod.slackBotAPI.PostMessage(channel, append(params, slack.MsgOptionText(message, true))...)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"channel": channel,
 			"error":   err,
 		}).Warn("Error while posting message to slack")
 	}
+	return channelID, ts
+}
+
+func (mod *service) updateMessageInSlack(channel string, ts string, message string, params ...slack.MsgOption) {
+	_, _, _, err := mod.slackBotAPI.UpdateMessage(channel, ts, append(params, slack.MsgOptionText(message, true))...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"channel": channel,
+			"ts":      ts,
+			"error":   err,
+		}).Warn("Error while updating message in slack")
+	}
+}
+
+// postReport posts the report's messages and records where they and the entries went.
+func (mod *service) postReport(tc *TeamConfig, sendTo string, r *report.Report, ref *ReportRef) {
+	ref.Entries = map[string]string{}
+	ref.Messages = []string{}
+	for i, m := range tc.reportMessages(r) {
+		opts := append([]slack.MsgOption{SlackParams}, m.options...)
+		target := sendTo
+		if i > 0 && tc.Mode() == ThreadMode {
+			target = ref.Channel
+			opts = append(opts, slack.MsgOptionTS(ref.Timestamp))
+		}
+
+		channel, ts := mod.postMessageToSlack(target, m.text, opts...)
+		if i == 0 {
+			ref.Channel, ref.Timestamp = channel, ts
+		}
+		ref.Messages = append(ref.Messages, ts)
+		for _, user := range m.users {
+			if _, ok := ref.Entries[user]; !ok {
+				ref.Entries[user] = ts
+			}
+		}
+	}
 }
 
 func (mod *service) SendReportForTeam(tc *TeamConfig, sendTo string) error {
-	today, err := common.ToDay(tc.Timezone)
+	return mod.sendReport(tc, sendTo, time.Now(), "")
+}
+
+// sendReport posts the report as it was due at the given time, prefixed by the note if there is one.
+func (mod *service) sendReport(tc *TeamConfig, sendTo string, at time.Time, note string) error {
+	today, err := tc.DayIn(tc.Timezone, at)
 	if err != nil {
 		return err
 	}
@@ -100,23 +212,27 @@ func (mod *service) SendReportForTeam(tc *TeamConfig, sendTo string) error {
 		return err
 	}
 
-	attachments, didNotDoReport := tc.GenerateReport(today, members)
-
-	if tc.SplitReport {
-		mod.postMessageToSlack(sendTo, ":parrotcop: Alrighty! Here's the scrum report for today!", SlackParams)
-		for i := 0; i < len(attachments); i++ {
-			mod.postMessageToSlack(sendTo, "*Scrum by:*", SlackParams, slack.MsgOptionAttachments(attachments[i]))
-		}
-	} else {
-		mod.postMessageToSlack(sendTo, ":parrotcop: Alrighty! Here's the scrum report for today!", SlackParams, slack.MsgOptionAttachments(attachments...))
+	if note != "" {
+		mod.postMessageToSlack(sendTo, note, SlackParams)
 	}
 
-	if len(didNotDoReport) > 0 {
-		mod.postMessageToSlack(sendTo, fmt.Sprintln("And lastly we should take a little time to shame", didNotDoReport), SlackParams)
+	ref := ReportRef{Date: today, Mode: tc.Mode(), Replies: map[string]string{}}
+	r := mod.buildReport(tc, at, members)
+	mod.postReport(tc, sendTo, r, &ref)
+
+	counted := !strings.HasPrefix(sendTo, "@")
+	if counted {
+		mod.countMissed(members, r.NonResponders)
 	}
+	ref.ShameTimestamp = mod.sendNonResponders(tc, sendTo, ref, r, tc.overGrace(members, r.NonResponders, counted))
 
 	if !strings.HasPrefix(sendTo, "@") {
+		mod.saveReportHistory(r)
+		mod.emailReport(tc, r)
+		mod.notify(tc, webhook.ReportSent, r)
+
 		tc.LastSendDate = today
+		tc.LastReport = ref
 		mod.SaveTeamConfig(tc)
 	}
 
@@ -128,31 +244,350 @@ func (mod *service) SendReportForTeam(tc *TeamConfig, sendTo string) error {
 	return nil
 }
 
+// buildReport builds the team's report with the mood trend and the blockers that are still open.
+func (mod *service) buildReport(tc *TeamConfig, at time.Time, members []*UserState) *report.Report {
+	r := tc.BuildReport(at, members)
+
+	if r.Mood != nil {
+		trend, err := mod.MoodTrend(tc, r.Date)
+		if err != nil {
+			log.WithError(err).Warn("Failed to get the mood trend")
+		} else {
+			r.Mood.Trend = trend
+		}
+	}
+
+	blockers, err := mod.OpenBlockers(tc.Name)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the open blockers")
+	} else {
+		r.Blockers = reportBlockers(blockers)
+	}
+
+	mod.flagStuck(tc, r)
+	mod.addStats(tc, r)
+	return r
+}
+
+// emailReport sends the report to the team's email recipients as markdown and HTML.
+func (mod *service) emailReport(tc *TeamConfig, r *report.Report) {
+	if mod.mailer == nil || len(tc.EmailRecipients) == 0 {
+		return
+	}
+
+	text, err := renderReport("markdown", r)
+	if err != nil {
+		log.WithError(err).Error("Failed to render the report as markdown")
+		return
+	}
+	html, err := renderReport("html", r)
+	if err != nil {
+		log.WithError(err).Error("Failed to render the report as HTML")
+		return
+	}
+
+	subject := fmt.Sprintf("%s scrum report for %s", tc.Name, r.Date)
+	err = mod.mailer.Send(tc.EmailRecipients, subject, text, html)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"team":       tc.Name,
+			"recipients": tc.EmailRecipients,
+			"error":      err,
+		}).Warn("Error while emailing the scrum report")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"team":       tc.Name,
+		"recipients": tc.EmailRecipients,
+	}).Info("Emailed scrum report.")
+}
+
+func renderReport(format string, r *report.Report) ([]byte, error) {
+	renderer, err := report.Get(format)
+	if err != nil {
+		return nil, err
+	}
+	return renderer.Render(r)
+}
+
+// SendLateEntry posts the member's entry as a reply to today's report if it was already sent
+// and takes them off the shame list. If their entry was in the report, it is updated in place.
+func (mod *service) SendLateEntry(tc *TeamConfig, us *UserState) error {
+	today, err := tc.DayIn(tc.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ref := tc.LastReport
+	if ref.Date != today || ref.Timestamp == "" {
+		// the report hasn't gone out yet, the entry will be part of it
+		return nil
+	}
+	if ts, ok := ref.Entries[us.User]; ok {
+		return mod.updateReportEntry(tc, us, ts)
+	}
+
+	entry := tc.entryMessage(time.Now(), us)
+	if entry == nil {
+		return nil
+	}
+
+	if us.MissedReports > 0 {
+		// late is better than never
+		us.MissedReports = 0
+		if err := mod.SaveUserState(us); err != nil {
+			return err
+		}
+	}
+
+	if ref.Replies == nil {
+		ref.Replies = map[string]string{}
+	}
+	if replyTs, ok := ref.Replies[us.User]; ok {
+		mod.updateMessageInSlack(ref.Channel, replyTs, "*Late scrum by:*", append(entry, SlackParams)...)
+	} else {
+		_, replyTs = mod.postMessageToSlack(ref.Channel, "*Late scrum by:*", append(entry, SlackParams, slack.MsgOptionTS(ref.Timestamp))...)
+		ref.Replies[us.User] = replyTs
+	}
+
+	members, err := mod.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return err
+	}
+	r := mod.buildReport(tc, time.Now(), members)
+	// keep the history up to date for the digest
+	mod.saveReportHistory(r)
+
+	if ref.ShameTimestamp != "" {
+		text, opts := tc.nonRespondersMessage(r, tc.overGrace(members, r.NonResponders, true))
+		mod.updateMessageInSlack(ref.Channel, ref.ShameTimestamp, text, append(opts, SlackParams)...)
+	}
+
+	tc.LastReport = ref
+	return mod.SaveTeamConfig(tc)
+}
+
+// asSent returns the members as they were when the report was sent, the ones that posted
+// their entry later as a late reply hadn't answered.
+func (ref *ReportRef) asSent(members []*UserState) []*UserState {
+	sent := []*UserState{}
+	for _, member := range members {
+		if _, late := ref.Replies[member.User]; late {
+			m := *member
+			m.Answers = map[string]string{}
+			m.Skipped = false
+			member = &m
+		}
+		sent = append(sent, member)
+	}
+	return sent
+}
+
+// updateReportEntry updates the member's entry in today's report after they edited it. In
+// split and thread modes the entry has its own message, otherwise the report is re-rendered
+// as it was sent.
+func (mod *service) updateReportEntry(tc *TeamConfig, us *UserState, ts string) error {
+	ref := tc.LastReport
+	members, err := mod.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return err
+	}
+
+	switch ref.Mode {
+	case SplitMode, ThreadMode:
+		entry := tc.entryMessage(time.Now(), us)
+		if entry == nil {
+			return nil
+		}
+		mod.updateMessageInSlack(ref.Channel, ts, tc.RenderEntryTitle(ref.Date), append(entry, SlackParams)...)
+	default:
+		messages := tc.reportMessages(mod.buildReport(tc, time.Now(), ref.asSent(members)))
+		if len(messages) != len(ref.Messages) {
+			log.WithFields(log.Fields{
+				"team": tc.Name,
+				"user": us.User,
+			}).Warn("The edited report doesn't fit in the messages it was sent as, it isn't updated")
+			return nil
+		}
+		for i, m := range messages {
+			mod.updateMessageInSlack(ref.Channel, ref.Messages[i], m.text, append(m.options, SlackParams)...)
+		}
+	}
+
+	// keep the history up to date for the digest
+	mod.saveReportHistory(mod.buildReport(tc, time.Now(), members))
+
+	log.WithFields(log.Fields{
+		"team": tc.Name,
+		"user": us.User,
+	}).Info("Updated the entry in the scrum report.")
+	return nil
+}
+
+// membersIn returns the members of the team whose timezone is tz.
+func (mod *service) membersIn(tc *TeamConfig, tz string) ([]*UserState, error) {
+	members, err := mod.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	in := []*UserState{}
+	for _, member := range members {
+		if tc.TimezoneFor(member) == tz {
+			in = append(in, member)
+		}
+	}
+	return in, nil
+}
+
+// MemberTimezones returns the distinct timezones of the team's members.
+func (mod *service) MemberTimezones(tc *TeamConfig) []string {
+	members, err := mod.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return []string{tc.Timezone}
+	}
+
+	zones := []string{}
+	seen := map[string]bool{}
+	for _, member := range members {
+		tz := tc.TimezoneFor(member)
+		if !seen[tz] {
+			seen[tz] = true
+			zones = append(zones, tz)
+		}
+	}
+	return zones
+}
+
+func (mod *service) SendPromptForTeam(tc *TeamConfig, tz string) error {
+	today, err := tc.DayIn(tz, time.Now())
+	if err != nil {
+		return err
+	}
+
+	members, err := mod.membersIn(tc, tz)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.OutOfOffice || member.HasReported(today, tc) {
+			continue
+		}
+		text := fmt.Sprintf("It's scrum time for team %s! Tell me `start` when you're ready, or `skip` if you have nothing to declare.", tc.Name)
+		mod.postMessageToSlack(member.dmChannel(), text, SlackParams, tc.fillInMessage(text))
+
+		member.PromptedAt = time.Now().UTC().Format(time.RFC3339)
+		if err := mod.SaveUserState(member); err != nil {
+			log.WithError(err).Warn("Failed to save when the member was prompted")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"team":     tc.Name,
+		"timezone": tz,
+	}).Info("Sent scrum prompts.")
+
+	return nil
+}
+
+func (mod *service) SendReminderForTeam(tc *TeamConfig, tz string) error {
+	reportDay, err := tc.DayIn(tc.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+	if tc.LastSendDate == reportDay {
+		// the report already went out, it's too late to say it goes out soon
+		log.WithFields(log.Fields{
+			"team":     tc.Name,
+			"timezone": tz,
+		}).Info("Report already sent, skipping the scrum reminders.")
+		return nil
+	}
+
+	today, err := tc.DayIn(tz, time.Now())
+	if err != nil {
+		return err
+	}
+
+	members, err := mod.membersIn(tc, tz)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.OutOfOffice || member.HasReported(today, tc) {
+			continue
+		}
+		text := fmt.Sprintf(":rotating_light: Friendly reminder, the scrum report for team %s goes out soon and I don't have yours yet. Tell me `start` to fill it in.", tc.Name)
+		mod.postMessageToSlack(member.dmChannel(), text, SlackParams, tc.fillInMessage(text))
+	}
+
+	log.WithFields(log.Fields{
+		"team":     tc.Name,
+		"timezone": tz,
+	}).Info("Sent scrum reminders.")
+
+	return nil
+}
+
 func (ss *service) RunReports() error {
+	teams, err := ss.GetAllTeams()
+	if err != nil {
+		return err
+	}
+
 	errs := []error{}
-	for _, tc := range ss.configurationProvider.Config().Teams {
-		ready, err := tc.ReadyToSendReport()
+	for _, tc := range teams {
+		ready, err := tc.ReadyToSendReport(time.Now())
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
 		fmt.Printf("team %s report ready:%v\n", tc.Name, ready)
 		if ready {
-			err = ss.SendReportForTeam(&tc, tc.Channel)
+			err = ss.SendReportForTeam(tc, tc.Channel)
 			if err != nil {
 				errs = append(errs, err)
 			}
 		}
 	}
 
-	if len(errs) > 0 {
-		msg := ""
-		for _, e := range errs {
-			msg += e.Error() + "\n"
+	return joinErrors(errs)
+}
+
+func (ss *service) OnDraftTimeout(handler func(tc *TeamConfig, us *UserState)) {
+	ss.draftTimeoutHandlers = append(ss.draftTimeoutHandlers, handler)
+}
+
+// finishStaleDrafts hands the answers the team's members stopped writing in multi-message
+// mode to the draft timeout handlers, the draft's time is stored so it is found even if the
+// function was frozen in the meantime. It tells if any draft was handed over.
+func (ss *service) finishStaleDrafts(tc *TeamConfig, now time.Time) (bool, error) {
+	if !tc.MultiMessageAnswers || len(ss.draftTimeoutHandlers) == 0 {
+		return false, nil
+	}
+
+	members, err := ss.GetAllTeamMembers(tc.Name)
+	if err != nil {
+		return false, err
+	}
+	finished := false
+	for _, member := range members {
+		if !tc.DraftTimedOut(member, now) {
+			continue
+		}
+		finished = true
+		log.WithFields(log.Fields{
+			"team": tc.Name,
+			"user": member.User,
+		}).Info("Answer timed out, taking the draft.")
+		for _, handler := range ss.draftTimeoutHandlers {
+			handler(tc, member)
 		}
-		return errors.New(msg)
 	}
-	return nil
+	return finished, nil
 }
 
 func (m *service) GetTeamForUser(username string) *TeamConfig {
@@ -179,7 +614,9 @@ func (m *service) GetAllTeamMembers(team string) ([]*UserState, error) {
 
 	all := []*UserState{}
 	for _, member := range tc.Members {
-		all = append(all, m.GetUserState(member))
+		us := m.GetUserState(member)
+		m.resolveMember(us)
+		all = append(all, us)
 	}
 
 	return all, nil
@@ -236,7 +673,7 @@ func (m *service) GetQuestionsForTeam(team string) []string {
 	if err != nil {
 		return []string{}
 	}
-	return tc.Questions
+	return tc.QuestionTexts()
 }
 
 func (m *service) SaveTeamConfig(tc *TeamConfig) error {
@@ -265,6 +702,11 @@ func (m *service) AddToOutOfOffice(username string) {
 
 	if err := m.SaveUserState(us); err != nil {
 		fmt.Println(err)
+		return
+	}
+
+	if tc := m.GetTeamForUser(username); tc != nil {
+		m.notify(tc, webhook.OutOfOffice, MemberEvent{User: username})
 	}
 }
 
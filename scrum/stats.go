@@ -0,0 +1,127 @@
+package scrum
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/nitrictech/go-sdk/api/documents"
+	"github.com/nitrictech/go-sdk/faas"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// streakDays is how far back the streaks are counted
+	streakDays = 60
+	// statsDays is the range of the stats endpoint if it isn't given one
+	statsDays = 30
+)
+
+// reportsBetween returns the team's reports from one date to the other included, oldest first.
+func (mod *service) reportsBetween(tc *TeamConfig, from, to string) ([]*report.Report, error) {
+	results, err := reportHistoryCol.Query().Where(
+		documents.Condition("team").Eq(documents.StringValue(tc.Name)),
+		documents.Condition("date").Ge(documents.StringValue(from)),
+		documents.Condition("date").Le(documents.StringValue(to)),
+	).Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []*report.Report{}
+	for _, doc := range results.Documents {
+		r := &report.Report{}
+		if err := decodeWithJsonTags(doc.Content(), r); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Date < reports[j].Date })
+	return reports, nil
+}
+
+// addStats puts the day's participation in the report, with the members' streaks over the
+// previous reports, and sums it up for the thread mode's header.
+func (mod *service) addStats(tc *TeamConfig, r *report.Report) {
+	r.Stats = report.NewStats(tc.Name, []*report.Report{r})
+
+	day, err := time.Parse(common.DateFormat, r.Date)
+	if err != nil {
+		return
+	}
+	history, err := mod.reportsBetween(tc, day.AddDate(0, 0, -streakDays).Format(common.DateFormat), day.AddDate(0, 0, -1).Format(common.DateFormat))
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the report history")
+		return
+	}
+
+	streaks := report.Streaks(append(history, r))
+	for i := range r.Stats.Members {
+		r.Stats.Members[i].Streak = streaks[r.Stats.Members[i].User]
+	}
+	r.Summary = participationSummary(r)
+}
+
+// StatsHandler returns the team's participation stats between the from and to query
+// parameters, the last 30 days by default, the user parameter keeps one member's.
+func (mod *service) StatsHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error) {
+	params := ctx.Request.PathParams()
+	if len(params) == 0 {
+		return common.HttpResponse(ctx, "error retrieving path params", 400)
+	}
+	query := ctx.Request.Query()
+
+	tc, err := mod.GetTeamByName(params["name"])
+	if err != nil {
+		return common.HttpResponse(ctx, "error retrieving team "+params["name"], 404)
+	}
+
+	to, err := tc.DayIn(tc.Timezone, time.Now())
+	if err != nil {
+		return common.HttpResponse(ctx, "error getting the team's date: "+err.Error(), 500)
+	}
+	if t, ok := query["to"]; ok && len(t) > 0 && t[0] != "" {
+		to = t[0]
+	}
+	toDay, err := time.Parse(common.DateFormat, to)
+	if err != nil {
+		return common.HttpResponse(ctx, "invalid to date, expected "+common.DateFormat, 400)
+	}
+
+	from := toDay.AddDate(0, 0, 1-statsDays).Format(common.DateFormat)
+	if f, ok := query["from"]; ok && len(f) > 0 && f[0] != "" {
+		from = f[0]
+	}
+	if _, err := time.Parse(common.DateFormat, from); err != nil || from > to {
+		return common.HttpResponse(ctx, "invalid from date, expected "+common.DateFormat+" before the to date", 400)
+	}
+
+	reports, err := mod.reportsBetween(tc, from, to)
+	if err != nil {
+		return common.HttpResponse(ctx, "error retrieving reports: "+err.Error(), 500)
+	}
+
+	stats := report.NewStats(tc.Name, reports)
+	stats.From, stats.To = from, to
+
+	var body interface{} = stats
+	if u, ok := query["user"]; ok && len(u) > 0 && u[0] != "" {
+		member := stats.Member(u[0])
+		if member == nil {
+			return common.HttpResponse(ctx, "no stats for "+u[0], 404)
+		}
+		body = member
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return common.HttpResponse(ctx, "error encoding stats: "+err.Error(), 500)
+	}
+
+	ctx.Response.Body = b
+	ctx.Response.Headers["Content-Type"] = []string{"application/json"}
+
+	return next(ctx)
+}
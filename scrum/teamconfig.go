@@ -1,101 +1,246 @@
 package scrum
 
 import (
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/asalkeld/scrumpolice/common"
-	colorful "github.com/lucasb-eyer/go-colorful"
-	"github.com/robfig/cron"
+	"github.com/asalkeld/scrumpolice/report"
 	"github.com/slack-go/slack"
 )
 
-func (tc *TeamConfig) ReadyToSendReport() (bool, error) {
-	now, err := common.NowWithLocation(tc.Timezone)
+// ReadyToSendReport tells if today's report is due and wasn't sent, or skipped, yet.
+func (tc *TeamConfig) ReadyToSendReport(now time.Time) (bool, error) {
+	today, err := tc.DayIn(tc.Timezone, now)
+	if err != nil || today == tc.LastSendDate {
+		// already sent, or skipped
+		return false, err
+	}
+	startOfDay, err := tc.StartOfDay(tc.Timezone, now)
 	if err != nil {
 		return false, err
 	}
 
-	c, err := cron.ParseStandard(tc.ReportScheduleCron)
+	scheduledTime, err := tc.LastSlot(ReportEvent, tc.Timezone, startOfDay, now)
 	if err != nil {
 		return false, err
 	}
 
-	newLayout := "15:04"
-	startOfDay, err := time.Parse(newLayout, "01:00")
+	return !scheduledTime.IsZero(), nil
+}
+
+// markReportSent records the report of the day at as sent, so it isn't sent again that day.
+func (tc *TeamConfig) markReportSent(at time.Time) error {
+	day, err := tc.DayIn(tc.Timezone, at)
 	if err != nil {
-		return false, err
+		return err
+	}
+	tc.LastSendDate = day
+	return nil
+}
+
+const (
+	// SingleMode posts the whole report in one message
+	SingleMode = "single"
+	// SplitMode posts each member's entry as a separate message in the channel
+	SplitMode = "split"
+	// ThreadMode posts a summary in the channel and each member's entry in its thread
+	ThreadMode = "thread"
+)
+
+// Mode returns how the report is delivered, splitReport is kept for older configurations.
+func (tc *TeamConfig) Mode() string {
+	if tc.ReportMode != "" {
+		return tc.ReportMode
+	}
+	if tc.SplitReport {
+		return SplitMode
+	}
+	return SingleMode
+}
+
+// participationSummary is a one line summary of who handed in their scrum, the stats line
+// once the report has its stats.
+func participationSummary(r *report.Report) string {
+	if r.Stats != nil {
+		return report.StatsText(r) + ". Entries are in the thread :thread:"
+	}
+
+	skipped := 0
+	for _, entry := range r.Entries {
+		if entry.Skipped {
+			skipped++
+		}
+	}
+
+	return fmt.Sprintf("*%d/%d* handed in their scrum, %d skipped, %d out of office and %d missing. Entries are in the thread :thread:",
+		len(r.Entries)-skipped, r.Members, skipped, len(r.OutOfOffice), len(r.NonResponders))
+}
+
+// TimezoneFor returns the member's timezone, the team's if they don't have one.
+func (tc *TeamConfig) TimezoneFor(us *UserState) string {
+	if us.Timezone != "" {
+		return us.Timezone
 	}
+	return tc.Timezone
+}
 
-	scheduledTime := c.Next(startOfDay)
+// DayIn returns the scrum date at the given time in the timezone, the date flips at the
+// team's dayStartsAt rather than midnight.
+func (tc *TeamConfig) DayIn(tz string, at time.Time) (string, error) {
+	offset, err := common.ParseDayStart(tc.DayStartsAt)
+	if err != nil {
+		return "", err
+	}
+	return common.DayAt(tz, offset, at)
+}
+
+// StartOfDay returns when the scrum day containing the given time started in the timezone.
+func (tc *TeamConfig) StartOfDay(tz string, at time.Time) (time.Time, error) {
+	offset, err := common.ParseDayStart(tc.DayStartsAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	start, err := common.StartOfDay(tz, offset, at)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *start, nil
+}
 
-	return now.After(scheduledTime), nil
+// DayFor returns the member's scrum date at the given time.
+func (tc *TeamConfig) DayFor(us *UserState, at time.Time) (string, error) {
+	return tc.DayIn(tc.TimezoneFor(us), at)
 }
 
-func (tc *TeamConfig) GenerateReport(today string, members []*UserState) ([]slack.Attachment, []string) {
-	attachments := []slack.Attachment{}
-	didNotDoReport := []string{}
-	outOfOffice := []string{}
+// BuildReport builds the report as it stands at the given time, each member's
+// entry is the one for their own date at that time.
+func (tc *TeamConfig) BuildReport(at time.Time, members []*UserState) *report.Report {
+	date, _ := tc.DayIn(tc.Timezone, at)
+	r := &report.Report{
+		Team:          tc.Name,
+		Date:          date,
+		Header:        tc.RenderHeader(date),
+		EntryTitle:    tc.RenderEntryTitle(date),
+		Members:       len(members),
+		Entries:       []report.Entry{},
+		OutOfOffice:   []string{},
+		NonResponders: []string{},
+		SlackIDs:      map[string]string{},
+	}
 
+	scores := []int{}
+	private := 0
 	for _, member := range members {
+		today, err := tc.DayFor(member, at)
 		answers := member.Answers
 		if len(answers) > 0 {
 			// don't keep reusing previous answers.
-			if member.LastAnswerDate != "" && member.LastAnswerDate != today {
+			if err != nil || (member.LastAnswerDate != "" && member.LastAnswerDate != today) {
 				answers = map[string]string{}
 			}
 		}
+		// skipping clears the answers, it still counts as handing in the scrum
+		skipped := err == nil && member.Skipped && member.LastAnswerDate == today
+
+		if score, ok := tc.MoodScoreFor(answers); ok && !member.OutOfOffice && !member.Skipped {
+			scores = append(scores, score)
+			if member.MoodPrivate {
+				private++
+			}
+		}
+		answers = tc.publicAnswers(member, answers)
+		if member.SlackID != "" {
+			r.SlackIDs[member.User] = member.SlackID
+		}
 
 		if member.OutOfOffice {
-			outOfOffice = append(outOfOffice, member.User)
+			r.OutOfOffice = append(r.OutOfOffice, member.User)
+		} else if skipped {
+			r.Entries = append(r.Entries, tc.reportEntry(date, member, answers))
 		} else if len(answers) == 0 {
-			didNotDoReport = append(didNotDoReport, "@"+member.User)
-		} else if member.Skipped {
-			attachment := slack.Attachment{
-				Color:      colorful.FastHappyColor().Hex(),
-				MarkdownIn: []string{"text", "pretext"},
-				Pretext:    "@" + member.User,
-				Text:       "Has nothing to declare.",
-			}
-			attachments = append(attachments, attachment)
+			r.NonResponders = append(r.NonResponders, member.User)
 		} else {
-			message := ""
-			for idx, q := range tc.Questions {
-				message += q + "\n" + answers[q]
-
-				if idx < len(tc.Questions)-1 {
-					message += "\n\n"
-				}
-			}
-
-			attachment := slack.Attachment{
-				Color:      colorful.FastHappyColor().Hex(),
-				MarkdownIn: []string{"text", "pretext"},
-				Pretext:    "@" + member.User,
-				Text:       message,
-			}
-			attachments = append(attachments, attachment)
+			r.Entries = append(r.Entries, tc.reportEntry(date, member, answers))
 		}
 	}
 
-	if len(outOfOffice) > 0 {
-		persons := outOfOffice[0]
-		verb := "is"
+	if len(r.OutOfOffice) > 0 {
+		r.OutOfOfficeText = tc.RenderOutOfOffice(date, mentions(r, r.OutOfOffice))
+	}
+	if len(r.NonResponders) > 0 {
+		r.NonRespondersText = tc.RenderNonResponders(date, mentions(r, r.NonResponders))
+	}
+	r.Summary = participationSummary(r)
+	r.Mood = moodSummary(scores, private)
+
+	return r
+}
+
+func (tc *TeamConfig) reportEntry(date string, us *UserState, answers map[string]string) report.Entry {
+	entry := report.Entry{User: us.User, SlackID: us.SlackID}
+	entry.Prompted, entry.Submitted = tc.submitTimes(us)
+
+	if us.Skipped {
+		entry.Skipped = true
+		entry.Text = tc.RenderSkipped(date, us.User)
+		return entry
+	}
 
-		if len(outOfOffice) > 1 {
-			persons = strings.Join(outOfOffice[0:(len(outOfOffice)-2)], ", ") + " and " + outOfOffice[(len(outOfOffice)-1)]
-			verb = "are"
+	entry.Text = tc.RenderEntry(date, us.User, answers)
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if _, answered := answers[q.Text]; !answered || !tc.Applies(q, answers) {
+			// a follow-up that wasn't asked or a private mood
+			continue
 		}
+		entry.Answers = append(entry.Answers, report.Answer{Question: q.Text, Answer: answers[q.Text], Display: q.Format(answers[q.Text]),
+			Plain: q.FormatPlain(answers[q.Text])})
+	}
+	return entry
+}
 
-		attachment := slack.Attachment{
-			Color:      colorful.FastHappyColor().Hex(),
-			MarkdownIn: []string{"text", "pretext"},
-			Pretext:    "Currently out of office",
-			Text:       persons + " " + verb + " currently out of office :sunglasses: :palm_tree:",
+// submitTimes returns when the member handed in their current entry and when they were
+// prompted for it, empty if it wasn't on the day of the entry.
+func (tc *TeamConfig) submitTimes(us *UserState) (string, string) {
+	onEntryDay := func(at string) bool {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return false
 		}
+		day, err := tc.DayFor(us, t)
+		return err == nil && day == us.LastAnswerDate
+	}
 
-		attachments = append(attachments, attachment)
+	if !onEntryDay(us.SubmittedAt) {
+		return "", ""
 	}
+	if !onEntryDay(us.PromptedAt) {
+		return "", us.SubmittedAt
+	}
+	return us.PromptedAt, us.SubmittedAt
+}
+
+// EntryFor returns the member's entry for their date at the given time.
+func (tc *TeamConfig) EntryFor(us *UserState, at time.Time) (string, report.Entry, error) {
+	date, err := tc.DayFor(us, at)
+	if err != nil {
+		return "", report.Entry{}, err
+	}
+	return date, tc.reportEntry(date, us, tc.publicAnswers(us, us.Answers)), nil
+}
+
+// mentions returns how the users are mentioned in Slack.
+func mentions(r *report.Report, users []string) []string {
+	m := []string{}
+	for _, u := range users {
+		m = append(m, r.Mention(u))
+	}
+	return m
+}
 
-	return attachments, didNotDoReport
+// GenerateReport returns the report's entries as attachments and who didn't do their report.
+func (tc *TeamConfig) GenerateReport(at time.Time, members []*UserState) ([]slack.Attachment, []string) {
+	r := tc.BuildReport(at, members)
+	return report.Attachments(r), mentions(r, r.NonResponders)
 }
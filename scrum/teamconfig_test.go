@@ -0,0 +1,378 @@
+package scrum
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/asalkeld/scrumpolice/webhook"
+	"github.com/slack-go/slack"
+)
+
+func TestDayInFlipsAtDayStartsAt(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC", DayStartsAt: "12:00"}
+
+	for _, at := range []time.Time{
+		time.Date(2022, 3, 21, 22, 0, 0, 0, time.UTC),
+		time.Date(2022, 3, 22, 6, 0, 0, 0, time.UTC),
+	} {
+		day, err := tc.DayIn(tc.Timezone, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if day != "2022-03-21" {
+			t.Errorf("expected the shift at %v to be on 2022-03-21, got %s", at, day)
+		}
+	}
+}
+
+func TestStartOfDayWithDayStartsAt(t *testing.T) {
+	tc := TeamConfig{Timezone: "UTC", DayStartsAt: "12:00"}
+
+	start, err := tc.StartOfDay(tc.Timezone, time.Date(2022, 3, 22, 6, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2022, 3, 21, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start of day %v", start)
+	}
+}
+
+func TestRenderEntryDefaultTemplate(t *testing.T) {
+	tc := TeamConfig{Questions: []Question{{Text: "Yesterday?"}, {Text: "Today?"}}}
+
+	entry := tc.RenderEntry("2022-03-21", "gfreeman", map[string]string{"Yesterday?": "crowbar", "Today?": "headcrabs"})
+	if entry != "Yesterday?\ncrowbar\n\nToday?\nheadcrabs" {
+		t.Errorf("unexpected entry %q", entry)
+	}
+}
+
+func TestRenderOutOfOfficeJoinsEveryone(t *testing.T) {
+	tc := TeamConfig{}
+
+	ooo := tc.RenderOutOfOffice("2022-03-21", []string{"a", "b", "c"})
+	if ooo != "a, b and c are currently out of office :sunglasses: :palm_tree:" {
+		t.Errorf("unexpected out of office %q", ooo)
+	}
+}
+
+func TestRenderUsesTeamTemplate(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Templates: ReportTemplates{Header: "Report for {{.Team}} on {{.Date}}"}}
+
+	header := tc.RenderHeader("2022-03-21")
+	if header != "Report for L337 on 2022-03-21" {
+		t.Errorf("unexpected header %q", header)
+	}
+}
+
+func TestValidateRejectsBrokenTemplates(t *testing.T) {
+	for _, rt := range []ReportTemplates{
+		{Header: "{{.Team"},
+		{Entry: "{{.Nope}}"},
+	} {
+		if err := rt.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", rt)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownSettings(t *testing.T) {
+	for _, tc := range []TeamConfig{
+		{ReportMode: "threaded"},
+		{ReportFormat: "kit"},
+		{MissedPolicy: "later"},
+		{MissedPolicy: MissedSendToAdmin},
+	} {
+		if err := tc.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", tc)
+		}
+	}
+
+	tc := TeamConfig{MissedPolicy: MissedSendToAdmin, Admin: "gfreeman"}
+	if err := tc.Validate(); err != nil {
+		t.Errorf("expected a valid team, got %v", err)
+	}
+}
+
+func TestBuildReportSortsMembers(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", Questions: []Question{{Text: "Yesterday?"}, {Text: "Today?"}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Yesterday?": "a", "Today?": "b"}},
+		{User: "wbreen", LastAnswerDate: "2022-03-20", Answers: map[string]string{"Yesterday?": "old", "Today?": "old"}},
+		{User: "evance", OutOfOffice: true},
+	}
+
+	r := tc.BuildReport(at, members)
+	if len(r.Entries) != 1 || r.Entries[0].User != "gfreeman" || len(r.Entries[0].Answers) != 2 {
+		t.Errorf("unexpected entries %+v", r.Entries)
+	}
+	if len(r.NonResponders) != 1 || r.NonResponders[0] != "wbreen" {
+		t.Errorf("unexpected non responders %v", r.NonResponders)
+	}
+	if len(r.OutOfOffice) != 1 || r.OutOfOffice[0] != "evance" {
+		t.Errorf("unexpected out of office %v", r.OutOfOffice)
+	}
+}
+
+func TestBlockersOnlyFromTheBlockerQuestion(t *testing.T) {
+	tc := TeamConfig{Questions: []Question{{Text: "Today?"}, {Text: "Are you being blocked by someone?"}}}
+
+	us := UserState{Answers: map[string]string{"Today?": "blocked UI", "Are you being blocked by someone?": "waiting on a review from wbreen"}}
+	if b := tc.Blockers(&us); len(b) != 0 {
+		t.Errorf("expected no blockers without a blocker question, got %v", b)
+	}
+
+	tc.BlockerQuestion = "Are you being blocked by someone?"
+	if b := tc.Blockers(&us); b["Are you being blocked by someone?"] != "waiting on a review from wbreen" || len(b) != 1 {
+		t.Errorf("expected the review blocker, got %v", b)
+	}
+
+	us.Answers["Are you being blocked by someone?"] = "Nope."
+	if b := tc.Blockers(&us); len(b) != 0 {
+		t.Errorf("expected no blockers, got %v", b)
+	}
+
+	tc.BlockerQuestion = "Blocked?"
+	if err := tc.Validate(); err == nil {
+		t.Error("expected a blocker question that isn't a question to be invalid")
+	}
+}
+
+func TestMentions(t *testing.T) {
+	mentions := Mentions("waiting on <@U024BE7LH|wbreen> and @evance. for @gfreeman's review <@U024BE7LH>")
+	expected := []string{"U024BE7LH", "evance", "gfreeman"}
+	if len(mentions) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mentions)
+	}
+	for i := range expected {
+		if mentions[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, mentions)
+		}
+	}
+}
+
+func TestBuildReportKeepsPrivateMoodOutOfEntries(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", MoodQuestion: "Mood?", Questions: []Question{{Text: "Today?"}, {Text: "Mood?", Type: ScaleQuestion}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "a", "Mood?": "4"}},
+		{User: "wbreen", MoodPrivate: true, LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "b", "Mood?": "1"}},
+		{User: "evance", MoodPrivate: true, LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "c", "Mood?": "4"}},
+	}
+
+	r := tc.BuildReport(at, members)
+	if r.Mood == nil || r.Mood.Responses != 3 || r.Mood.Average != 3 || r.Mood.Distribution[0] != 1 {
+		t.Errorf("unexpected mood %+v", r.Mood)
+	}
+	if len(r.Entries[0].Answers) != 2 || len(r.Entries[1].Answers) != 1 || strings.Contains(r.Entries[1].Text, "Mood?") {
+		t.Errorf("expected wbreen's mood to be private, got %+v", r.Entries)
+	}
+}
+
+func TestMoodHiddenWhenItGivesScoresAway(t *testing.T) {
+	tests := []struct {
+		scores  []int
+		private int
+		shown   bool
+	}{
+		{[]int{4}, 0, false},
+		{[]int{4, 2}, 0, false},
+		{[]int{4, 2, 3}, 1, false},
+		{[]int{4, 2, 3}, 0, true},
+		{[]int{4, 2, 3}, 2, true},
+	}
+
+	for _, test := range tests {
+		if m := moodSummary(test.scores, test.private); (m != nil) != test.shown {
+			t.Errorf("%v with %d private: expected shown %v, got %+v", test.scores, test.private, test.shown, m)
+		}
+	}
+}
+
+func TestNonRespondersGraceAndPolicy(t *testing.T) {
+	tc := TeamConfig{NonResponderGrace: 2}
+	members := []*UserState{
+		{User: "gfreeman", MissedReports: 1},
+		{User: "wbreen", MissedReports: 0},
+	}
+	nonResponders := []string{"gfreeman", "wbreen"}
+
+	if over := tc.overGrace(members, nonResponders, false); len(over) != 1 || over[0] != "gfreeman" {
+		t.Errorf("expected only gfreeman to be over the grace, got %v", over)
+	}
+	if over := tc.overGrace(members, nonResponders, true); len(over) != 0 {
+		t.Errorf("expected no one over the grace once counted, got %v", over)
+	}
+
+	r := &report.Report{Date: "2022-03-21"}
+	if text, _ := tc.nonRespondersMessage(r, []string{"gfreeman"}); !strings.Contains(text, "@gfreeman") {
+		t.Errorf("expected a mention, got %q", text)
+	}
+	tc.NonResponderPolicy = ListNonResponders
+	if text, _ := tc.nonRespondersMessage(r, []string{"gfreeman"}); strings.Contains(text, "@") || !strings.Contains(text, "gfreeman") {
+		t.Errorf("expected a plain list, got %q", text)
+	}
+}
+
+func TestBuildReportMentionsBySlackID(t *testing.T) {
+	tc := TeamConfig{Name: "a", Timezone: "UTC", Questions: []Question{{Text: "Yesterday?"}}}
+	members := []*UserState{
+		{User: "Gordon Freeman", SlackID: "U123", Answers: map[string]string{"Yesterday?": "crowbar"}},
+		{User: "wbreen", SlackID: "U456", OutOfOffice: true},
+		{User: "alyx"},
+	}
+
+	r := tc.BuildReport(time.Now(), members)
+	if r.Entries[0].SlackID != "U123" || r.Mention("Gordon Freeman") != "<@U123>" {
+		t.Errorf("expected gfreeman to be mentioned by ID, got %+v", r.Entries[0])
+	}
+	if !strings.Contains(r.OutOfOfficeText, "<@U456>") {
+		t.Errorf("expected wbreen to be mentioned by ID, got %q", r.OutOfOfficeText)
+	}
+	if !strings.Contains(r.NonRespondersText, "@alyx") {
+		t.Errorf("expected alyx to fall back to the name, got %q", r.NonRespondersText)
+	}
+}
+
+func TestReportMessagesTrackEntries(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", Questions: []Question{{Text: "Today?"}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "a"}},
+		{User: "wbreen", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "b"}},
+	}
+	r := tc.BuildReport(at, members)
+
+	for _, format := range []string{AttachmentsFormat, BlocksFormat} {
+		tc.ReportFormat = format
+
+		tc.ReportMode = SplitMode
+		messages := tc.reportMessages(r)
+		if len(messages) != 3 || len(messages[0].users) != 0 || messages[2].users[0] != "wbreen" {
+			t.Errorf("%s: expected an entry per message, got %+v", format, messages)
+		}
+
+		tc.ReportMode = SingleMode
+		messages = tc.reportMessages(r)
+		if len(messages) != 1 || len(messages[0].users) != 2 {
+			t.Errorf("%s: expected every entry in one message, got %+v", format, messages)
+		}
+	}
+}
+
+func TestThreadSummaryIsTheStats(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", ReportMode: ThreadMode, Questions: []Question{{Text: "Today?"}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "a"}},
+		{User: "wbreen", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "b"}},
+	}
+	r := tc.BuildReport(at, members)
+	r.Stats = report.NewStats(tc.Name, []*report.Report{r})
+	r.Summary = participationSummary(r)
+
+	if !strings.HasPrefix(r.Summary, report.StatsText(r)) {
+		t.Errorf("expected the summary to be the stats, got %q", r.Summary)
+	}
+	for _, format := range []string{AttachmentsFormat, BlocksFormat} {
+		tc.ReportFormat = format
+		if messages := tc.reportMessages(r); len(messages) != 3 {
+			t.Errorf("%s: expected the header and an entry per message without a stats footer, got %+v", format, messages)
+		}
+	}
+}
+
+func TestBuildReportCountsSkippedMembers(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", Questions: []Question{{Text: "Today?"}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Skipped: true, Answers: map[string]string{}},
+		{User: "wbreen", LastAnswerDate: "2022-03-20", Skipped: true, Answers: map[string]string{}},
+	}
+
+	r := tc.BuildReport(at, members)
+	if len(r.Entries) != 1 || !r.Entries[0].Skipped || r.Entries[0].User != "gfreeman" {
+		t.Errorf("expected a skipped entry, got %+v", r.Entries)
+	}
+	if len(r.NonResponders) != 1 || r.NonResponders[0] != "wbreen" {
+		t.Errorf("expected only yesterday's skipper to be missing, got %v", r.NonResponders)
+	}
+}
+
+func TestWebhookSecretsAreRedactedAndKept(t *testing.T) {
+	stored := map[string]interface{}{
+		"name": "L337",
+		"webhooks": []interface{}{
+			map[string]interface{}{"url": "https://example.com/scrum", "secret": "s3cret", "events": []interface{}{}},
+		},
+	}
+
+	tc := &TeamConfig{Name: "L337", Webhooks: []webhook.Endpoint{
+		{URL: "https://example.com/scrum", Secret: redactedSecret},
+		{URL: "https://example.com/new", Secret: "n3w"},
+	}}
+	keepSecrets(tc, stored)
+	if tc.Webhooks[0].Secret != "s3cret" || tc.Webhooks[1].Secret != "n3w" {
+		t.Errorf("expected the stored secret to be kept, got %+v", tc.Webhooks)
+	}
+
+	redacted := redactSecrets(stored)["webhooks"].([]interface{})[0].(map[string]interface{})
+	if redacted["secret"] != redactedSecret {
+		t.Errorf("expected the secret to be redacted, got %v", redacted["secret"])
+	}
+}
+
+func TestIndexPrefersDisplayNames(t *testing.T) {
+	users := []slack.User{
+		{ID: "U1", Name: "gordon", Profile: slack.UserProfile{DisplayName: "gfreeman"}},
+		{ID: "U2", Name: "gfreeman", Profile: slack.UserProfile{DisplayName: "gordon"}},
+		{ID: "U3", Name: "eli", RealName: "Eli Vance", Profile: slack.UserProfile{DisplayName: "evance"}},
+	}
+
+	ids := index(users)
+	expected := map[string]string{"gfreeman": "U1", "gordon": "U2", "evance": "U3", "eli": "U3", "eli vance": "U3"}
+	for name, id := range expected {
+		if ids[name] != id {
+			t.Errorf("expected %s to be %s, got %s", name, id, ids[name])
+		}
+	}
+}
+
+func TestReportAsSentLeavesOutLateReplies(t *testing.T) {
+	tc := TeamConfig{Name: "L337", Timezone: "UTC", Questions: []Question{{Text: "Today?"}}}
+	at := time.Date(2022, 3, 21, 9, 0, 0, 0, time.UTC)
+	ref := ReportRef{Entries: map[string]string{"gfreeman": "1"}, Replies: map[string]string{"wbreen": "2"}}
+	members := []*UserState{
+		{User: "gfreeman", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "a"}},
+		{User: "wbreen", LastAnswerDate: "2022-03-21", Answers: map[string]string{"Today?": "b"}},
+		{User: "evance"},
+	}
+
+	r := tc.BuildReport(at, ref.asSent(members))
+	if r.Members != 3 || len(r.Entries) != 1 || len(r.NonResponders) != 2 {
+		t.Errorf("expected the report as it was sent, got %+v", r)
+	}
+	if members[1].Answers["Today?"] != "b" {
+		t.Errorf("expected the member's state to be left alone, got %+v", members[1])
+	}
+}
+
+func TestMembersGetTheirSlackTimezone(t *testing.T) {
+	zones := timezones([]slack.User{{ID: "U1", TZ: "Australia/Brisbane"}, {ID: "U2"}})
+	if len(zones) != 1 || zones["U1"] != "Australia/Brisbane" {
+		t.Errorf("unexpected timezones %v", zones)
+	}
+
+	us := &UserState{User: "gfreeman"}
+	if !us.fillFromSlack("U1", zones["U1"]) || us.SlackID != "U1" || us.Timezone != "Australia/Brisbane" {
+		t.Errorf("expected the member to get their Slack ID and timezone, got %+v", us)
+	}
+	if us.fillFromSlack("U1", zones["U1"]) {
+		t.Errorf("expected nothing to change the second time")
+	}
+	if us.fillFromSlack("U2", "Europe/Paris") || us.Timezone != "Australia/Brisbane" {
+		t.Errorf("expected another user's timezone to be ignored, got %+v", us)
+	}
+}
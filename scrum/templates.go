@@ -0,0 +1,183 @@
+package scrum
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReportTemplates overrides the wording of a team's report with text/template
+// templates, an empty template uses the default wording.
+//
+// header and entryTitle are executed with ReportData, entry and skipped with
+// EntryData, outOfOffice and nonResponders with PeopleData.
+type ReportTemplates struct {
+	Header        string `json:"header"`
+	EntryTitle    string `json:"entryTitle"`
+	Entry         string `json:"entry"`
+	Skipped       string `json:"skipped"`
+	OutOfOffice   string `json:"outOfOffice"`
+	NonResponders string `json:"nonResponders"`
+}
+
+// ReportData is what the header and entryTitle templates get.
+type ReportData struct {
+	Team string
+	Date string
+}
+
+// QuestionAnswer is a question and what the member answered, Answer is formatted for
+// the question's type (e.g. a scale as emoji) and Value is the answer as given.
+type QuestionAnswer struct {
+	Question string
+	Answer   string
+	Value    string
+}
+
+// EntryData is what the entry and skipped templates get.
+type EntryData struct {
+	ReportData
+	User    string
+	Answers []QuestionAnswer
+}
+
+// PeopleData is what the outOfOffice and nonResponders templates get. Persons is
+// Users joined as "a, b and c" and Verb is "is" or "are" to go with it.
+type PeopleData struct {
+	ReportData
+	Users   []string
+	Persons string
+	Verb    string
+}
+
+const (
+	DefaultHeaderTemplate        = ":parrotcop: Alrighty! Here's the scrum report for today!"
+	DefaultEntryTitleTemplate    = "*Scrum by:*"
+	DefaultEntryTemplate         = "{{range $i, $qa := .Answers}}{{if $i}}\n\n{{end}}{{$qa.Question}}\n{{$qa.Answer}}{{end}}"
+	DefaultSkippedTemplate       = "Has nothing to declare."
+	DefaultOutOfOfficeTemplate   = "{{.Persons}} {{.Verb}} currently out of office :sunglasses: :palm_tree:"
+	DefaultNonRespondersTemplate = "And lastly we should take a little time to shame {{.Users}}\n"
+)
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func (rt *ReportTemplates) all() map[string]string {
+	return map[string]string{
+		"header":        rt.Header,
+		"entryTitle":    rt.EntryTitle,
+		"entry":         rt.Entry,
+		"skipped":       rt.Skipped,
+		"outOfOffice":   rt.OutOfOffice,
+		"nonResponders": rt.NonResponders,
+	}
+}
+
+// Validate parses every template and executes it against sample data.
+func (rt *ReportTemplates) Validate() error {
+	samples := map[string]interface{}{
+		"header":        ReportData{},
+		"entryTitle":    ReportData{},
+		"entry":         EntryData{Answers: []QuestionAnswer{{}}},
+		"skipped":       EntryData{},
+		"outOfOffice":   PeopleData{Users: []string{""}},
+		"nonResponders": PeopleData{Users: []string{""}},
+	}
+
+	for name, text := range rt.all() {
+		if text == "" {
+			continue
+		}
+		if _, err := executeTemplate(name, text, samples[name]); err != nil {
+			return fmt.Errorf("template %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func executeTemplate(name, text string, data interface{}) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// render executes the team's template, falling back to the default one if it fails.
+func (tc *TeamConfig) render(name, text, def string, data interface{}) string {
+	if text != "" {
+		out, err := executeTemplate(name, text, data)
+		if err == nil {
+			return out
+		}
+		log.WithFields(log.Fields{
+			"team":     tc.Name,
+			"template": name,
+			"error":    err,
+		}).Warn("Failed to render report template, using the default")
+	}
+
+	out, err := executeTemplate(name, def, data)
+	if err != nil {
+		log.WithError(err).Error("Failed to render default report template")
+	}
+	return out
+}
+
+func (tc *TeamConfig) RenderHeader(date string) string {
+	return tc.render("header", tc.Templates.Header, DefaultHeaderTemplate, ReportData{Team: tc.Name, Date: date})
+}
+
+func (tc *TeamConfig) RenderEntryTitle(date string) string {
+	return tc.render("entryTitle", tc.Templates.EntryTitle, DefaultEntryTitleTemplate, ReportData{Team: tc.Name, Date: date})
+}
+
+func (tc *TeamConfig) RenderEntry(date string, user string, answers map[string]string) string {
+	data := EntryData{ReportData: ReportData{Team: tc.Name, Date: date}, User: user}
+	for i := range tc.Questions {
+		q := &tc.Questions[i]
+		if _, answered := answers[q.Text]; !answered || !tc.Applies(q, answers) {
+			continue
+		}
+		data.Answers = append(data.Answers, QuestionAnswer{Question: q.Text, Answer: q.Format(answers[q.Text]), Value: answers[q.Text]})
+	}
+	return tc.render("entry", tc.Templates.Entry, DefaultEntryTemplate, data)
+}
+
+func (tc *TeamConfig) RenderSkipped(date string, user string) string {
+	data := EntryData{ReportData: ReportData{Team: tc.Name, Date: date}, User: user}
+	return tc.render("skipped", tc.Templates.Skipped, DefaultSkippedTemplate, data)
+}
+
+func (tc *TeamConfig) RenderOutOfOffice(date string, users []string) string {
+	return tc.render("outOfOffice", tc.Templates.OutOfOffice, DefaultOutOfOfficeTemplate, tc.peopleData(date, users))
+}
+
+func (tc *TeamConfig) RenderNonResponders(date string, users []string) string {
+	return tc.render("nonResponders", tc.Templates.NonResponders, DefaultNonRespondersTemplate, tc.peopleData(date, users))
+}
+
+func (tc *TeamConfig) peopleData(date string, users []string) PeopleData {
+	data := PeopleData{ReportData: ReportData{Team: tc.Name, Date: date}, Users: users, Verb: "is"}
+	if len(users) == 0 {
+		return data
+	}
+
+	data.Persons = users[0]
+	if len(users) > 1 {
+		data.Persons = strings.Join(users[:len(users)-1], ", ") + " and " + users[len(users)-1]
+		data.Verb = "are"
+	}
+	return data
+}
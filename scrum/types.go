@@ -1,14 +1,62 @@
 package scrum
 
+import "github.com/asalkeld/scrumpolice/webhook"
+
 type TeamConfig struct {
-	Name               string   `json:"name"`
-	Channel            string   `json:"channel"`
-	Members            []string `json:"members"`
-	Questions          []string `json:"questions"`
-	ReportScheduleCron string   `json:"reportScheduleCron"`
-	Timezone           string   `json:"timezone"`
-	LastSendDate       string   `json:"lastSendDate"`
-	SplitReport        bool     `json:"splitReport"`
+	Name                 string             `json:"name"`
+	Channel              string             `json:"channel"`
+	Members              []string           `json:"members"`
+	Questions            []Question         `json:"questions"`
+	BlockerQuestion      string             `json:"blockerQuestion"`
+	MoodQuestion         string             `json:"moodQuestion"`
+	MoodTrendDays        int                `json:"moodTrendDays"`
+	ReportScheduleCron   string             `json:"reportScheduleCron"`
+	PromptScheduleCron   string             `json:"promptScheduleCron"`
+	ReminderScheduleCron string             `json:"reminderScheduleCron"`
+	DigestScheduleCron   string             `json:"digestScheduleCron"`
+	Timezone             string             `json:"timezone"`
+	DayStartsAt          string             `json:"dayStartsAt"`
+	LastSendDate         string             `json:"lastSendDate"`
+	LastReport           ReportRef          `json:"lastReport"`
+	SplitReport          bool               `json:"splitReport"`
+	ReportMode           string             `json:"reportMode"`
+	ReportFormat         string             `json:"reportFormat"`
+	Templates            ReportTemplates    `json:"templates"`
+	MultiMessageAnswers  bool               `json:"multiMessageAnswers"`
+	AnswerDoneEmoji      string             `json:"answerDoneEmoji"`
+	AnswerTimeoutMinutes int                `json:"answerTimeoutMinutes"`
+	Admin                string             `json:"admin"`
+	NonResponderPolicy   NonResponderPolicy `json:"nonResponderPolicy"`
+	NonResponderGrace    int                `json:"nonResponderGrace"`
+	MissedPolicy         MissedPolicy       `json:"missedPolicy"`
+	EmailRecipients      []string           `json:"emailRecipients"`
+	Webhooks             []webhook.Endpoint `json:"webhooks"`
+}
+
+// MissedPolicy is what to do with a report, prompt or reminder that wasn't sent on time.
+type MissedPolicy string
+
+const (
+	// MissedSendLate sends it anyway with a note (the default)
+	MissedSendLate MissedPolicy = "late"
+	// MissedSkip drops it
+	MissedSkip MissedPolicy = "skip"
+	// MissedSendToAdmin sends a missed report to the team admin only
+	MissedSendToAdmin MissedPolicy = "admin"
+)
+
+// ReportRef records where the last report was posted so late entries can be threaded to it.
+// Timestamp is the first message of the report, the thread's parent in thread mode.
+// Entries is the message holding each member's entry so edits can update it in place.
+type ReportRef struct {
+	Date           string            `json:"date"`
+	Mode           string            `json:"mode"`
+	Channel        string            `json:"channel"`
+	Timestamp      string            `json:"timestamp"`
+	ShameTimestamp string            `json:"shameTimestamp"`
+	Replies        map[string]string `json:"replies"`
+	Entries        map[string]string `json:"entries"`
+	Messages       []string          `json:"messages"`
 }
 
 type Config struct {
@@ -17,10 +65,20 @@ type Config struct {
 
 type UserState struct {
 	User           string            `json:"user"`
+	SlackID        string            `json:"slackID"`
 	GithubUser     string            `json:"githubUser"`
+	Timezone       string            `json:"timezone"`
 	OutOfOffice    bool              `json:"outOfOffice"`
+	MoodPrivate    bool              `json:"moodPrivate"`
+	MissedReports  int               `json:"missedReports"`
 	Started        bool              `json:"started"`
+	Editing        string            `json:"editing"`
+	EditingDate    string            `json:"editingDate"`
+	Draft          string            `json:"draft"`
+	DraftAt        string            `json:"draftAt"`
 	Skipped        bool              `json:"skipped"`
 	LastAnswerDate string            `json:"lastAnswerDate"`
+	PromptedAt     string            `json:"promptedAt"`
+	SubmittedAt    string            `json:"submittedAt"`
 	Answers        map[string]string `json:"answers"`
 }
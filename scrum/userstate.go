@@ -0,0 +1,10 @@
+package scrum
+
+// HasReported tells if the member answered all the team's questions that apply to them,
+// or skipped, on the given day.
+func (us *UserState) HasReported(today string, tc *TeamConfig) bool {
+	if us.LastAnswerDate != today {
+		return false
+	}
+	return us.Skipped || tc.NextQuestion(us) == nil
+}
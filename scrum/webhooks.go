@@ -0,0 +1,173 @@
+package scrum
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/asalkeld/scrumpolice/common"
+	"github.com/asalkeld/scrumpolice/report"
+	"github.com/asalkeld/scrumpolice/webhook"
+	"github.com/nitrictech/go-sdk/api/documents"
+	"github.com/nitrictech/go-sdk/faas"
+	log "github.com/sirupsen/logrus"
+)
+
+// EntryEvent is the data of an entry.submitted event.
+type EntryEvent struct {
+	Date string `json:"date"`
+	report.Entry
+}
+
+// MemberEvent is the data of a member.outOfOffice event.
+type MemberEvent struct {
+	User string `json:"user"`
+}
+
+// BlockerEvent is the data of a blocker.reported event.
+type BlockerEvent struct {
+	ID       string   `json:"id"`
+	Date     string   `json:"date"`
+	User     string   `json:"user"`
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Mentions []string `json:"mentions"`
+}
+
+// redactedSecret is shown instead of the webhook secrets by the configuration API, a team
+// sent back with it keeps its stored secret.
+const redactedSecret = "********"
+
+// redactSecrets hides the webhook secrets of a team document.
+func redactSecrets(doc map[string]interface{}) map[string]interface{} {
+	webhooks, _ := doc["webhooks"].([]interface{})
+	for _, w := range webhooks {
+		if ep, ok := w.(map[string]interface{}); ok && ep["secret"] != "" && ep["secret"] != nil {
+			ep["secret"] = redactedSecret
+		}
+	}
+	return doc
+}
+
+// keepSecrets puts back the stored secrets of the webhooks sent with the redacted secret.
+func keepSecrets(tc *TeamConfig, stored map[string]interface{}) {
+	old := &TeamConfig{}
+	if err := decodeWithJsonTags(stored, old); err != nil {
+		return
+	}
+	for i := range tc.Webhooks {
+		if tc.Webhooks[i].Secret != redactedSecret {
+			continue
+		}
+		tc.Webhooks[i].Secret = ""
+		for _, ep := range old.Webhooks {
+			if ep.URL == tc.Webhooks[i].URL {
+				tc.Webhooks[i].Secret = ep.Secret
+			}
+		}
+	}
+}
+
+// notify sends the event to the team's webhooks, through the registered publishers if
+// there are any since the deliveries can take longer than the caller can wait.
+func (mod *service) notify(tc *TeamConfig, event string, data interface{}) {
+	if mod.webhooks == nil || len(tc.Webhooks) == 0 {
+		return
+	}
+	if len(mod.notifyPublishers) == 0 {
+		mod.webhooks.Dispatch(tc.Name, tc.Webhooks, event, data)
+		return
+	}
+	for _, publish := range mod.notifyPublishers {
+		publish(tc.Name, event, data)
+	}
+}
+
+func (mod *service) OnNotify(publisher func(team, event string, data interface{})) {
+	mod.notifyPublishers = append(mod.notifyPublishers, publisher)
+}
+
+// DeliverWebhook sends an event handed to a publisher to the team's webhooks.
+func (mod *service) DeliverWebhook(team, event string, data interface{}) error {
+	tc, err := mod.GetTeamByName(team)
+	if err != nil {
+		return err
+	}
+	mod.webhooks.Dispatch(tc.Name, tc.Webhooks, event, data)
+	return nil
+}
+
+// EntrySubmitted keeps track of what is blocking the member and lets the team's webhooks
+// know they handed in their scrum.
+func (mod *service) EntrySubmitted(tc *TeamConfig, us *UserState) {
+	date, entry, err := tc.EntryFor(us, time.Now())
+	if err != nil {
+		log.WithError(err).Warn("Failed to build the entry for the webhooks")
+		return
+	}
+	mod.notify(tc, webhook.EntrySubmitted, EntryEvent{Date: date, Entry: entry})
+
+	if us.Skipped {
+		return
+	}
+	mod.saveMoodScore(tc, us, date)
+	if err := mod.trackBlockers(tc, us, date); err != nil {
+		log.WithError(err).Warn("Failed to track blockers")
+	}
+}
+
+// logDelivery keeps the outcome of a webhook delivery in the delivery log.
+func (mod *service) logDelivery(d webhook.Delivery) {
+	fields := log.Fields{
+		"team":     d.Team,
+		"event":    d.Event,
+		"url":      d.URL,
+		"attempts": d.Attempts,
+	}
+	if d.Succeeded {
+		log.WithFields(fields).Info("Delivered webhook.")
+	} else {
+		fields["error"] = d.Error
+		log.WithFields(fields).Warn("Failed to deliver webhook")
+	}
+
+	deliveryMap := map[string]interface{}{}
+	err := decodeWithJsonTags(d, &deliveryMap)
+	if err == nil {
+		err = deliveryCol.Doc(d.ID).Set(deliveryMap)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Failed to save webhook delivery")
+	}
+}
+
+// DeliveriesHandler lists the team's webhook deliveries.
+func (mod *service) DeliveriesHandler(ctx *faas.HttpContext, next faas.HttpHandler) (*faas.HttpContext, error) {
+	params := ctx.Request.PathParams()
+	if len(params) == 0 {
+		return common.HttpResponse(ctx, "error retrieving path params", 400)
+	}
+
+	results, err := deliveryCol.Query().Where(documents.Condition("team").Eq(documents.StringValue(params["name"]))).Fetch()
+	if err != nil {
+		return common.HttpResponse(ctx, "error retrieving deliveries: "+err.Error(), 500)
+	}
+
+	deliveries := []webhook.Delivery{}
+	for _, doc := range results.Documents {
+		d := webhook.Delivery{}
+		if err := decodeWithJsonTags(doc.Content(), &d); err != nil {
+			return common.HttpResponse(ctx, "error decoding delivery: "+err.Error(), 500)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	b, err := json.Marshal(deliveries)
+	if err != nil {
+		return common.HttpResponse(ctx, "error encoding deliveries: "+err.Error(), 500)
+	}
+
+	ctx.Response.Body = b
+	ctx.Response.Headers["Content-Type"] = []string{"application/json"}
+
+	return next(ctx)
+}
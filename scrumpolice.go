@@ -4,8 +4,10 @@ import (
 	_ "embed"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/asalkeld/scrumpolice/bot"
+	"github.com/asalkeld/scrumpolice/email"
 	"github.com/asalkeld/scrumpolice/scrum"
 	"github.com/nitrictech/go-sdk/resources"
 	"github.com/sirupsen/logrus"
@@ -31,16 +33,37 @@ func main() {
 	slackAPIClient := slack.New(slackBotToken)
 	spApi := resources.NewApi("scrumpolice")
 	sc := scrum.NewConfig()
-	ss, err := scrum.NewService(sc, slackAPIClient)
+
+	var mailer *email.Sender
+	smtpConfig, err := email.ConfigFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if smtpConfig != nil {
+		mailer = email.NewSender(*smtpConfig)
+	}
+
+	ss, err := scrum.NewService(sc, slackAPIClient, mailer)
 	if err != nil {
 		panic(err)
 	}
 
-	b := bot.New(slackAPIClient, logger, ss)
+	signingSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	if signingSecret == "" {
+		logger.Warn("SLACK_SIGNING_SECRET isn't set, slash commands and interactions will be refused")
+	}
+	work, err := resources.NewTopic(bot.WorkTopic, resources.TopicPublishing)
+	if err != nil {
+		panic(err)
+	}
+	b := bot.New(slackAPIClient, logger, ss, signingSecret, work)
+	work.Subscribe(b.WorkHandler)
 
 	sc.ReloadAndDistributeChange()
 
 	spApi.Post("/events", b.EventHandler)
+	spApi.Post("/slash", b.SlashHandler)
+	spApi.Post("/interactions", b.InteractionHandler)
 
 	spApi.Post("/config", sc.PostHandler)
 	spApi.Get("/config", sc.ListHandler)
@@ -48,6 +71,10 @@ func main() {
 	spApi.Get("/config/:name", sc.GetHandler)
 	spApi.Put("/config/:name", sc.PutHandler)
 
+	spApi.Get("/report/:name", ss.ReportHandler)
+	spApi.Get("/webhooks/:name/deliveries", ss.DeliveriesHandler)
+	spApi.Get("/stats/:name", ss.StatsHandler)
+
 	err = resources.Run()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,208 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	ReportSent      = "report.sent"
+	EntrySubmitted  = "entry.submitted"
+	OutOfOffice     = "member.outOfOffice"
+	BlockerReported = "blocker.reported"
+
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the body with the endpoint's secret
+	SignatureHeader = "X-Scrumpolice-Signature"
+	EventHeader     = "X-Scrumpolice-Event"
+	DeliveryHeader  = "X-Scrumpolice-Delivery"
+)
+
+// Endpoint is a URL a team registered to get events on, all events if Events is empty.
+type Endpoint struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// Wants tells if the endpoint subscribed to the event.
+func (e *Endpoint) Wants(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body POSTed to the endpoints.
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	Team      string      `json:"team"`
+	Timestamp string      `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is the outcome of sending a payload to an endpoint, after all the attempts.
+type Delivery struct {
+	ID         string `json:"id"`
+	Team       string `json:"team"`
+	Event      string `json:"event"`
+	URL        string `json:"url"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error"`
+	Succeeded  bool   `json:"succeeded"`
+	Time       string `json:"time"`
+}
+
+// Dispatcher POSTs events to endpoints, retrying failed deliveries with exponential backoff.
+// The deliveries are done before Dispatch returns since the function it runs in may be frozen
+// afterwards, Budget bounds how long each one takes.
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+	Budget      time.Duration
+	// OnDelivery is called with the outcome of every delivery, to keep a log of them
+	OnDelivery func(d Delivery)
+}
+
+func NewDispatcher(onDelivery func(d Delivery)) *Dispatcher {
+	return &Dispatcher{
+		Client:      &http.Client{},
+		MaxAttempts: 5,
+		Backoff:     500 * time.Millisecond,
+		Budget:      10 * time.Second,
+		OnDelivery:  onDelivery,
+	}
+}
+
+// Dispatch sends the event to each endpoint that wants it, side by side, and waits for the
+// deliveries.
+func (d *Dispatcher) Dispatch(team string, endpoints []Endpoint, event string, data interface{}) {
+	var wg sync.WaitGroup
+	for _, ep := range endpoints {
+		if !ep.Wants(event) {
+			continue
+		}
+		wg.Add(1)
+		go func(ep Endpoint) {
+			defer wg.Done()
+			d.Deliver(ep, NewPayload(team, event, data))
+		}(ep)
+	}
+	wg.Wait()
+}
+
+func NewPayload(team, event string, data interface{}) Payload {
+	return Payload{
+		ID:        newID(),
+		Event:     event,
+		Team:      team,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	}
+}
+
+// Deliver POSTs the payload to the endpoint until it gets a 2xx, a 4xx other than 429,
+// runs out of attempts or out of the dispatcher's budget.
+func (d *Dispatcher) Deliver(ep Endpoint, p Payload) Delivery {
+	delivery := Delivery{ID: p.ID, Team: p.Team, Event: p.Event, URL: ep.URL}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		delivery.Error = err.Error()
+		return d.done(delivery)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Budget)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	backoff := d.Backoff
+	for delivery.Attempts < d.MaxAttempts {
+		if delivery.Attempts > 0 {
+			if time.Until(deadline) < backoff {
+				// no time left to try again
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		delivery.Attempts++
+
+		retry := false
+		delivery.StatusCode, retry, err = d.post(ctx, ep, p, body)
+		if err == nil {
+			delivery.Error = ""
+			delivery.Succeeded = true
+			break
+		}
+		delivery.Error = err.Error()
+		if !retry {
+			break
+		}
+	}
+
+	return d.done(delivery)
+}
+
+func (d *Dispatcher) done(delivery Delivery) Delivery {
+	delivery.Time = time.Now().UTC().Format(time.RFC3339)
+	if d.OnDelivery != nil {
+		d.OnDelivery(delivery)
+	}
+	return delivery
+}
+
+// post returns the status code and if it is worth trying again on error.
+func (d *Dispatcher) post(ctx context.Context, ep Endpoint, p Payload, body []byte) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, p.Event)
+	req.Header.Set(DeliveryHeader, p.ID)
+	if ep.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(ep.Secret, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retry, fmt.Errorf("endpoint returned %s", resp.Status)
+}
+
+// Sign returns the signature header value of the body for the secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+
+		if r.Header.Get(SignatureHeader) != Sign("s3cret", body) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get(EventHeader) != ReportSent {
+			t.Errorf("expected event %s, got %s", ReportSent, r.Header.Get(EventHeader))
+		}
+
+		p := Payload{}
+		if err := json.Unmarshal(body, &p); err != nil || p.Team != "L337" {
+			t.Errorf("unexpected payload %s", body)
+		}
+
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	logged := []Delivery{}
+	d := NewDispatcher(func(d Delivery) { logged = append(logged, d) })
+	d.Backoff = time.Millisecond
+
+	delivery := d.Deliver(Endpoint{URL: server.URL, Secret: "s3cret"}, NewPayload("L337", ReportSent, map[string]string{"date": "2022-03-01"}))
+
+	if !delivery.Succeeded || delivery.Attempts != 3 || delivery.StatusCode != 200 {
+		t.Errorf("expected success after 3 attempts, got %+v", delivery)
+	}
+	if len(logged) != 1 || logged[0].ID != delivery.ID {
+		t.Errorf("expected the delivery to be logged once, got %+v", logged)
+	}
+}
+
+func TestDeliverDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(nil)
+	d.Backoff = time.Millisecond
+
+	delivery := d.Deliver(Endpoint{URL: server.URL}, NewPayload("L337", EntrySubmitted, nil))
+
+	if delivery.Succeeded || calls != 1 || delivery.StatusCode != 404 {
+		t.Errorf("expected a single failed attempt, got %+v after %d calls", delivery, calls)
+	}
+}
+
+func TestEndpointWants(t *testing.T) {
+	all := Endpoint{}
+	some := Endpoint{Events: []string{OutOfOffice}}
+
+	if !all.Wants(ReportSent) || !some.Wants(OutOfOffice) || some.Wants(ReportSent) {
+		t.Fail()
+	}
+}
+
+func TestDeliverStopsWhenOutOfBudget(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(nil)
+	d.Backoff = 20 * time.Millisecond
+	d.Budget = 50 * time.Millisecond
+
+	delivery := d.Deliver(Endpoint{URL: server.URL}, NewPayload("L337", ReportSent, nil))
+	if delivery.Succeeded || calls != 2 {
+		t.Errorf("expected to give up after 2 attempts, got %+v after %d calls", delivery, calls)
+	}
+}